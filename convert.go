@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+const (
+	mysqlDateTimeLayout = "2006-01-02 15:04:05.999999"
+	mysqlDateLayout     = "2006-01-02"
+	mysqlTimeLayout     = "15:04:05.999999"
+)
+
+// ConversionSetting - value conversion between the source and the target
+type ConversionSetting struct {
+	TimeZone string `yaml:"time_zone"` // zone of the time values written to the target, UTC when empty
+}
+
+// TimeZones - zones of the time values on the source and the target
+type TimeZones struct {
+	Source *time.Location // zone of the datetime values on the source, nil keeps the wall clock
+	Target *time.Location // zone of the time values written to the target, UTC when nil
+}
+
+// loadZone - location of the zone name; UTC, Local, offsets like +09:00 or IANA names
+func loadZone(name string) (*time.Location, error) {
+	if len(name) <= 0 || strings.EqualFold(name, "UTC") {
+		return time.UTC, nil
+	} else if strings.EqualFold(name, "Local") {
+		return time.Local, nil
+	}
+	if at, err := time.Parse("-07:00", name); err == nil {
+		_, offset := at.Zone()
+		return time.FixedZone(name, offset), nil
+	}
+	return time.LoadLocation(name)
+}
+
+// Location - zone of the time values written to the target, UTC on an invalid zone
+func (c ConversionSetting) Location() *time.Location {
+	zone, err := loadZone(c.TimeZone)
+	if err != nil {
+		fmt.Printf("invalid time zone %s: %s\n", c.TimeZone, err.Error())
+		return time.UTC
+	}
+	return zone
+}
+
+// target - zone of the time values written to the target
+func (z TimeZones) target() *time.Location {
+	if z.Target == nil {
+		return time.UTC
+	}
+	return z.Target
+}
+
+// isNaiveTime - whether the source column holds times without a zone
+func isNaiveTime(def ColumnDefinition) bool {
+	switch sourceTypeName(def.SourceType) {
+	case "datetime", "datetime2", "smalldatetime", "date":
+		return true
+	}
+	return false
+}
+
+// wallClock - the wall clock of the time in the zone
+func wallClock(at time.Time, zone *time.Location) time.Time {
+	return time.Date(at.Year(), at.Month(), at.Day(), at.Hour(), at.Minute(), at.Second(), at.Nanosecond(), zone)
+}
+
+// watermarkValue - watermark to persist of the scanned index value,
+// naive source times are located in the source zone
+func (z TimeZones) watermarkValue(def ColumnDefinition, v interface{}) interface{} {
+	if at, ok := v.(time.Time); ok && z.Source != nil && isNaiveTime(def) {
+		return wallClock(at, z.Source)
+	}
+	return v
+}
+
+// watermarkParam - watermark to compare with the source index column,
+// naive source times as the wall clock in the source zone, as they are scanned
+func (z TimeZones) watermarkParam(def ColumnDefinition, v interface{}) interface{} {
+	if at, ok := v.(time.Time); ok && z.Source != nil && isNaiveTime(def) {
+		return wallClock(at.In(z.Source), time.UTC)
+	}
+	return v
+}
+
+// sourceTypeName - type name of the source type, decimal of decimal(18,2)
+func sourceTypeName(sourcetype string) string {
+	return strings.ToLower(strings.SplitN(sourcetype, "(", 2)[0])
+}
+
+// convertValue - normalize the scanned source value for the target column.
+// GUIDs become canonical strings, decimals exact strings, times strings in the target zone
+// and binaries bytes
+func convertValue(def ColumnDefinition, v interface{}, zones TimeZones) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch sourceTypeName(def.SourceType) {
+	case "uniqueidentifier":
+		var guid mssql.UniqueIdentifier
+		if err := guid.Scan(v); err == nil {
+			return guid.String()
+		}
+	case "decimal", "numeric", "money", "smallmoney":
+		switch value := v.(type) {
+		case []byte:
+			return string(value)
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	case "datetimeoffset":
+		if at, ok := v.(time.Time); ok {
+			return at.In(zones.target()).Format(mysqlDateTimeLayout)
+		}
+	case "datetime", "datetime2", "smalldatetime":
+		// wall clock in the source zone, kept as is without the zone
+		if at, ok := v.(time.Time); ok {
+			if zones.Source != nil {
+				at = wallClock(at, zones.Source).In(zones.target())
+			}
+			return at.Format(mysqlDateTimeLayout)
+		}
+	case "date":
+		if at, ok := v.(time.Time); ok {
+			return at.Format(mysqlDateLayout)
+		}
+	case "time":
+		if at, ok := v.(time.Time); ok {
+			return at.Format(mysqlTimeLayout)
+		}
+	case "binary", "varbinary", "image", "timestamp", "rowversion":
+		switch value := v.(type) {
+		case []byte:
+			return append([]byte{}, value...)
+		case string:
+			return []byte(value)
+		}
+	case "bit":
+		if value, ok := v.(bool); ok {
+			if value {
+				return 1
+			}
+			return 0
+		}
+	case "char", "nchar", "varchar", "nvarchar", "text", "ntext", "xml", "sysname":
+		if value, ok := v.([]byte); ok {
+			return string(value)
+		}
+	}
+	return v
+}
+
+// convertRow - normalize the row values in mapping order
+func convertRow(mappings []ColumnMapping, row []interface{}, zones TimeZones) []interface{} {
+	for i, m := range mappings {
+		row[i] = convertValue(m.Source, row[i], zones)
+	}
+	return row
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type ConvertSample struct {
+	SourceType string
+	Value      interface{}
+	Expect     interface{}
+}
+
+func TestConvertValue(t *testing.T) {
+	seoul := time.FixedZone("+09:00", 9*60*60)
+	at := time.Date(2021, 5, 10, 12, 30, 15, 123456700, time.UTC)
+	offset := time.Date(2021, 5, 10, 21, 30, 15, 0, seoul)
+	// 6F9619FF-8B86-D011-B42D-00C04FC964FF in SQL Server byte order
+	guid := []byte{0xFF, 0x19, 0x96, 0x6F, 0x86, 0x8B, 0x11, 0xD0, 0xB4, 0x2D, 0x00, 0xC0, 0x4F, 0xC9, 0x64, 0xFF}
+
+	samples := []ConvertSample{
+		{"uniqueidentifier", guid, "6F9619FF-8B86-D011-B42D-00C04FC964FF"},
+		{"uniqueidentifier", nil, nil},
+		{"decimal(18,4)", []byte("1234.5600"), "1234.5600"},
+		{"numeric(38,10)", []byte("12345678901234567890.0123456789"), "12345678901234567890.0123456789"},
+		{"money", []byte("-0.0100"), "-0.0100"},
+		{"smallmoney", float64(2.5), "2.5"},
+		{"datetimeoffset(7)", offset, "2021-05-10 12:30:15"},
+		{"datetime2(7)", at, "2021-05-10 12:30:15.123456"},
+		{"datetime", at, "2021-05-10 12:30:15.123456"},
+		{"smalldatetime", at, "2021-05-10 12:30:15.123456"},
+		{"date", at, "2021-05-10"},
+		{"time(7)", at, "12:30:15.123456"},
+		{"bit", true, 1},
+		{"bit", false, 0},
+		{"nvarchar(50)", "김진영", "김진영"},
+		{"varchar(50)", []byte("abc"), "abc"},
+		{"int", int64(10), int64(10)},
+		{"float", float64(0.1), float64(0.1)},
+	}
+
+	for i, s := range samples {
+		v := convertValue(ColumnDefinition{SourceType: s.SourceType}, s.Value, TimeZones{})
+		if v != s.Expect {
+			t.Errorf("[%d] %s expected %v but %v", i, s.SourceType, s.Expect, v)
+		}
+	}
+
+	// binaries are copied as bytes
+	for i, s := range []ConvertSample{
+		{"varbinary(max)", []byte{0, 1, 2}, []byte{0, 1, 2}},
+		{"binary(8)", "ab", []byte("ab")},
+		{"timestamp", []byte{0, 0, 0, 0, 0, 0, 7, 209}, []byte{0, 0, 0, 0, 0, 0, 7, 209}},
+	} {
+		v, ok := convertValue(ColumnDefinition{SourceType: s.SourceType}, s.Value, TimeZones{}).([]byte)
+		if !ok || !bytes.Equal(v, s.Expect.([]byte)) {
+			t.Errorf("[%d] %s expected %v but %v", i, s.SourceType, s.Expect, v)
+		}
+	}
+
+	// datetimeoffset in the target zone
+	if v := convertValue(ColumnDefinition{SourceType: "datetimeoffset(7)"}, offset.UTC(), TimeZones{Target: seoul}); v != "2021-05-10 21:30:15" {
+		t.Errorf("datetimeoffset in +09:00 expected 2021-05-10 21:30:15 but %v", v)
+	}
+	// naive datetime from the source zone to the target zone
+	zones := TimeZones{Source: seoul}
+	if v := convertValue(ColumnDefinition{SourceType: "datetime"}, at, zones); v != "2021-05-10 03:30:15.123456" {
+		t.Errorf("datetime from +09:00 expected 2021-05-10 03:30:15.123456 but %v", v)
+	}
+	zones.Target = seoul
+	if v := convertValue(ColumnDefinition{SourceType: "datetime"}, at, zones); v != "2021-05-10 12:30:15.123456" {
+		t.Errorf("datetime in +09:00 expected 2021-05-10 12:30:15.123456 but %v", v)
+	}
+}
+
+func TestWatermarkZones(t *testing.T) {
+	seoul := time.FixedZone("+09:00", 9*60*60)
+	def := ColumnDefinition{Name: "UpdatedAt", SourceType: "datetime"}
+	// scanned wall clock labeled UTC
+	scanned := time.Date(2021, 5, 10, 12, 30, 15, 0, time.UTC)
+
+	// no source zone keeps the value
+	if v := (TimeZones{}).watermarkValue(def, scanned); v != scanned {
+		t.Errorf("watermark without zone expected %v but %v", scanned, v)
+	}
+
+	zones := TimeZones{Source: seoul}
+	stored, ok := zones.watermarkValue(def, scanned).(time.Time)
+	if !ok || !stored.Equal(time.Date(2021, 5, 10, 3, 30, 15, 0, time.UTC)) {
+		t.Errorf("watermark expected 03:30:15 UTC but %v", stored)
+	}
+	// read back as another zone, the parameter is the source wall clock
+	param := zones.watermarkParam(def, stored.In(time.Local))
+	if param != scanned {
+		t.Errorf("watermark parameter expected %v but %v", scanned, param)
+	}
+
+	// zoned and non-time columns are kept
+	offset := ColumnDefinition{Name: "At", SourceType: "datetimeoffset"}
+	if v := zones.watermarkValue(offset, scanned); v != scanned {
+		t.Errorf("datetimeoffset watermark expected %v but %v", scanned, v)
+	}
+	if v := zones.watermarkParam(ColumnDefinition{SourceType: "int"}, int64(10)); v != int64(10) {
+		t.Errorf("int watermark expected 10 but %v", v)
+	}
+}
+
+func TestLoadZone(t *testing.T) {
+	samples := map[string]int{
+		"":       0,
+		"UTC":    0,
+		"+09:00": 9 * 60 * 60,
+		"-05:30": -(5*60 + 30) * 60,
+	}
+	for name, expect := range samples {
+		zone, err := loadZone(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+			continue
+		}
+		if _, offset := time.Date(2021, 1, 1, 0, 0, 0, 0, zone).Zone(); offset != expect {
+			t.Errorf("%s expected offset %d but %d", name, expect, offset)
+		}
+	}
+	if zone := (ConversionSetting{TimeZone: "Nowhere/Invalid"}).Location(); zone != time.UTC {
+		t.Errorf("invalid zone expected UTC but %s", zone)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultCharset = "utf8mb4" // charset of generated tables
+)
+
+// unicodeCharsets - MySQL charsets holding every unicode character
+var unicodeCharsets = []string{"utf8mb4", "utf16", "utf16le", "utf32"}
+
+// TableDefinition - target table to create
+type TableDefinition struct {
+	Name      string
+	Columns   []ColumnDefinition
+	Charset   string // table and string column charset, server default when empty
+	Collation string // table and string column collation, charset default when empty
+
+	PrimaryKey []string          // primary key columns
+	Indexes    []IndexDefinition // secondary indexes
+}
+
+// isStringType - whether the MySQL type holds characters
+func isStringType(datatype string) bool {
+	datatype = strings.ToLower(datatype)
+	for _, prefix := range []string{"char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set"} {
+		if datatype == prefix || strings.HasPrefix(datatype, prefix+"(") {
+			return true
+		}
+	}
+	return false
+}
+
+// isIntegerType - whether the MySQL type is an integer, the types AUTO_INCREMENT applies to
+func isIntegerType(datatype string) bool {
+	datatype = strings.ToLower(strings.Fields(datatype + " ")[0])
+	for _, prefix := range []string{"tinyint", "smallint", "mediumint", "int", "integer", "bigint"} {
+		if datatype == prefix || strings.HasPrefix(datatype, prefix+"(") {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnicodeType - whether the source type stores unicode
+func isUnicodeType(sourcetype string) bool {
+	sourcetype = strings.ToLower(sourcetype)
+	return strings.HasPrefix(sourcetype, "nchar") ||
+		strings.HasPrefix(sourcetype, "nvarchar") ||
+		sourcetype == "ntext"
+}
+
+// charsetOptions - charset and collation clauses, formatted by the option formats
+func (td TableDefinition) charsetOptions(charset string, collation string) string {
+	options := make([]string, 0, 2)
+	if 0 < len(td.Charset) {
+		options = append(options, fmt.Sprintf(charset, td.Charset))
+	}
+	if 0 < len(td.Collation) {
+		options = append(options, fmt.Sprintf(collation, td.Collation))
+	}
+	return strings.Join(options, " ")
+}
+
+// columnQuery - column clause of the CREATE TABLE
+func (td TableDefinition) columnQuery(col ColumnDefinition) string {
+	parts := []string{quoteMySQL(col.Name), col.DataType}
+	if isStringType(col.DataType) {
+		if options := td.charsetOptions("CHARACTER SET %s", "COLLATE %s"); 0 < len(options) {
+			parts = append(parts, options)
+		}
+	}
+	if col.Computed {
+		storage := "VIRTUAL"
+		if col.Persisted {
+			storage = "STORED"
+		}
+		parts = append(parts, fmt.Sprintf("AS (%s) %s", col.Expression, storage))
+	}
+	if !col.Nullable {
+		parts = append(parts, "NOT NULL")
+	}
+	if !col.Computed && 0 < len(col.Default) {
+		parts = append(parts, "DEFAULT "+col.Default)
+	}
+	if col.Identity && isIntegerType(col.DataType) {
+		parts = append(parts, "AUTO_INCREMENT")
+	}
+	return strings.Join(parts, " ")
+}
+
+// CreateQuery - CREATE TABLE statement of the definition
+func (td TableDefinition) CreateQuery() string {
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
+		quoteMySQL(td.Name), strings.Join(td.definitions(), ","))
+	if options := td.charsetOptions("DEFAULT CHARSET=%s", "COLLATE=%s"); 0 < len(options) {
+		query += " " + options
+	}
+	return query
+}
+
+// CreateScript - create table statement laid out a definition per line, for review
+func (td TableDefinition) CreateScript() string {
+	script := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)",
+		quoteMySQL(td.Name), strings.Join(td.definitions(), ",\n  "))
+	if options := td.charsetOptions("DEFAULT CHARSET=%s", "COLLATE=%s"); 0 < len(options) {
+		script += " " + options
+	}
+	return script + ";\n"
+}
+
+// definitions - column, primary key and index definitions of the table
+func (td TableDefinition) definitions() []string {
+	cols := make([]string, len(td.Columns), len(td.Columns)+len(td.Indexes)+1)
+	for i, col := range td.Columns {
+		cols[i] = td.columnQuery(col)
+	}
+	if 0 < len(td.PrimaryKey) {
+		cols = append(cols, td.primaryKeyQuery())
+	}
+	for _, index := range td.Indexes {
+		cols = append(cols, td.keyQuery(index))
+	}
+	return cols
+}
+
+// buildMySQLTable - create the table of the definition on the target
+func buildMySQLTable(db *sql.DB, td TableDefinition) error {
+	query := td.CreateQuery()
+	fmt.Println(query)
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("%s not created: %s", td.Name, err.Error())
+	}
+	return nil
+}
+
+// readMySQLColumnCharsets - charset of the string columns(key) of the target table
+func readMySQLColumnCharsets(db *sql.DB, table string) map[string]string {
+	query := "SELECT COLUMN_NAME, CHARACTER_SET_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=? AND CHARACTER_SET_NAME IS NOT NULL"
+	charsets := make(map[string]string)
+	rows, err := queryFetchAll(db, query, table)
+	if err != nil {
+		fmt.Println(err.Error())
+		return charsets
+	}
+	for _, row := range rows {
+		charsets[strings.ToLower(transformString(row[0]))] = transformString(row[1])
+	}
+	return charsets
+}
+
+// charsetWarnings - unicode source columns landing on target columns of a non-unicode charset
+func charsetWarnings(mappings []ColumnMapping, charsets map[string]string) []string {
+	warnings := make([]string, 0)
+	for _, m := range mappings {
+		if !isUnicodeType(m.Source.SourceType) {
+			continue
+		}
+		charset, exists := charsets[strings.ToLower(m.Target.Name)]
+		if exists && !containsName(unicodeCharsets, charset) {
+			warnings = append(warnings, fmt.Sprintf("column `%s` is %s, but [%s] is %s",
+				m.Target.Name, charset, m.Source.Name, m.Source.SourceType))
+		}
+	}
+	return warnings
+}
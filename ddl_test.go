@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCreateQuery(t *testing.T) {
+	td := TableDefinition{
+		Name: "Cleansed_Dataset",
+		Columns: []ColumnDefinition{
+			{Name: "id", DataType: "int", Nullable: false},
+			{Name: "Campaign name", DataType: "varchar(50)", Nullable: true},
+			{Name: "Description", DataType: "longtext", Nullable: true},
+		},
+		Charset:   "utf8mb4",
+		Collation: "utf8mb4_unicode_ci",
+	}
+	expect := "CREATE TABLE IF NOT EXISTS `Cleansed_Dataset` (" +
+		"`id` int NOT NULL," +
+		"`Campaign name` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci," +
+		"`Description` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci" +
+		") DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+	if q := td.CreateQuery(); q != expect {
+		t.Errorf("expected %s but %s", expect, q)
+	}
+
+	td.Charset, td.Collation = "", ""
+	expect = "CREATE TABLE IF NOT EXISTS `Cleansed_Dataset` (`id` int NOT NULL,`Campaign name` varchar(50),`Description` longtext)"
+	if q := td.CreateQuery(); q != expect {
+		t.Errorf("expected %s but %s", expect, q)
+	}
+}
+
+func TestCreateScript(t *testing.T) {
+	td := TableDefinition{
+		Name: "Cleansed_Dataset",
+		Columns: []ColumnDefinition{
+			{Name: "id", DataType: "int", Nullable: false},
+			{Name: "Campaign name", DataType: "varchar(50)", Nullable: true},
+		},
+		Charset:    "utf8mb4",
+		PrimaryKey: []string{"id"},
+	}
+	expect := "CREATE TABLE IF NOT EXISTS `Cleansed_Dataset` (\n" +
+		"  `id` int NOT NULL,\n" +
+		"  `Campaign name` varchar(50) CHARACTER SET utf8mb4,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") DEFAULT CHARSET=utf8mb4;\n"
+	if q := td.CreateScript(); q != expect {
+		t.Errorf("expected %s but %s", expect, q)
+	}
+}
+
+func TestCharsetWarnings(t *testing.T) {
+	mappings := mapColumns([]ColumnDefinition{
+		{Name: "name_ko", DataType: "varchar(50)", SourceType: "nvarchar(50)"},
+		{Name: "code", DataType: "varchar(10)", SourceType: "varchar(10)"},
+		{Name: "memo", DataType: "longtext", SourceType: "ntext"},
+		{Name: "title", DataType: "char(10)", SourceType: "nchar(10)"},
+	}, ColumnSelection{})
+	charsets := map[string]string{
+		"name_ko": "latin1",
+		"code":    "latin1",
+		"memo":    "utf8mb4",
+		"title":   "utf8",
+	}
+
+	warnings := charsetWarnings(mappings, charsets)
+	if len(warnings) != 2 {
+		t.Errorf("expected warnings on name_ko and title but %v", warnings)
+	}
+	for _, w := range warnings {
+		t.Log(w)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	DiffAdded   = "added"   // on the source, missing on the target
+	DiffRemoved = "removed" // on the target, not on the source
+	DiffChanged = "changed" // on both, defined differently
+)
+
+// ColumnDiff - difference of a column between the translated source and the target
+type ColumnDiff struct {
+	Column  string   `json:"column"`
+	Change  string   `json:"change"`
+	Source  string   `json:"source,omitempty"` // expected definition
+	Target  string   `json:"target,omitempty"` // live definition
+	Details []string `json:"details,omitempty"`
+}
+
+// IndexDiff - difference of a key between the translated source and the target
+type IndexDiff struct {
+	Index  string `json:"index"`
+	Change string `json:"change"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+// TableDiff - differences of a table, Missing when the target table does not exist
+type TableDiff struct {
+	Source  string       `json:"source"`
+	Target  string       `json:"target"`
+	Missing bool         `json:"missing,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Columns []ColumnDiff `json:"columns,omitempty"`
+	Indexes []IndexDiff  `json:"indexes,omitempty"`
+}
+
+// Empty - whether the table has no difference
+func (d TableDiff) Empty() bool {
+	return !d.Missing && len(d.Error) <= 0 && len(d.Columns) <= 0 && len(d.Indexes) <= 0
+}
+
+var integerWidthPattern = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+// normalizeDataType - data type comparable to SHOW COLUMNS output,
+// integer display widths are left out as MySQL 8 does
+func normalizeDataType(datatype string) string {
+	datatype = strings.ToLower(strings.TrimSpace(datatype))
+	datatype = strings.Replace(datatype, "integer", "int", 1)
+	return integerWidthPattern.ReplaceAllString(datatype, "$1")
+}
+
+// nullability - NULL or NOT NULL
+func nullability(nullable bool) string {
+	if nullable {
+		return "NULL"
+	}
+	return "NOT NULL"
+}
+
+// describeColumn - data type and nullability of the column
+func describeColumn(col ColumnDefinition) string {
+	return col.DataType + " " + nullability(col.Nullable)
+}
+
+// diffColumns - columns added, removed or changed on the live target against the expected columns
+func diffColumns(expected []ColumnDefinition, live []ColumnDefinition) []ColumnDiff {
+	diffs := make([]ColumnDiff, 0)
+	for _, e := range expected {
+		found := false
+		for _, l := range live {
+			if !strings.EqualFold(e.Name, l.Name) {
+				continue
+			}
+			found = true
+			details := make([]string, 0)
+			if normalizeDataType(e.DataType) != normalizeDataType(l.DataType) {
+				details = append(details, fmt.Sprintf("type %s -> %s", e.DataType, l.DataType))
+			}
+			if e.Nullable != l.Nullable {
+				details = append(details, fmt.Sprintf("%s -> %s", nullability(e.Nullable), nullability(l.Nullable)))
+			}
+			if 0 < len(details) {
+				diffs = append(diffs, ColumnDiff{Column: e.Name, Change: DiffChanged, Source: describeColumn(e), Target: describeColumn(l), Details: details})
+			}
+			break
+		}
+		if !found {
+			diffs = append(diffs, ColumnDiff{Column: e.Name, Change: DiffAdded, Source: describeColumn(e)})
+		}
+	}
+	for _, l := range live {
+		found := false
+		for _, e := range expected {
+			found = found || strings.EqualFold(e.Name, l.Name)
+		}
+		if !found {
+			diffs = append(diffs, ColumnDiff{Column: l.Name, Change: DiffRemoved, Target: describeColumn(l)})
+		}
+	}
+	return diffs
+}
+
+// describeIndex - uniqueness and columns of the index
+func describeIndex(index IndexDefinition) string {
+	kind := "KEY"
+	if index.Name == "PRIMARY" {
+		kind = "PRIMARY KEY"
+	} else if index.Unique {
+		kind = "UNIQUE KEY"
+	}
+	return fmt.Sprintf("%s (%s)", kind, strings.Join(index.Columns, ","))
+}
+
+// sameColumns - whether the column lists are the same, case-insensitively
+func sameColumns(left []string, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		if !strings.EqualFold(left[i], right[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// diffIndexes - primary key and indexes added, removed or changed on the live target
+func diffIndexes(td TableDefinition, live []IndexDefinition) []IndexDiff {
+	expected := append([]IndexDefinition{}, td.Indexes...)
+	if 0 < len(td.PrimaryKey) {
+		expected = append([]IndexDefinition{{Name: "PRIMARY", Unique: true, Columns: td.PrimaryKey}}, expected...)
+	}
+
+	diffs := make([]IndexDiff, 0)
+	for _, e := range expected {
+		found := false
+		for _, l := range live {
+			if !strings.EqualFold(e.Name, l.Name) {
+				continue
+			}
+			found = true
+			if e.Unique != l.Unique || !sameColumns(e.Columns, l.Columns) {
+				diffs = append(diffs, IndexDiff{Index: e.Name, Change: DiffChanged, Source: describeIndex(e), Target: describeIndex(l)})
+			}
+			break
+		}
+		if !found {
+			diffs = append(diffs, IndexDiff{Index: e.Name, Change: DiffAdded, Source: describeIndex(e)})
+		}
+	}
+	for _, l := range live {
+		found := false
+		for _, e := range expected {
+			found = found || strings.EqualFold(e.Name, l.Name)
+		}
+		if !found {
+			diffs = append(diffs, IndexDiff{Index: l.Name, Change: DiffRemoved, Target: describeIndex(l)})
+		}
+	}
+	return diffs
+}
+
+// diffTable - differences of the task table on the target
+func (tt *TransferTask) diffTable() TableDiff {
+	diff := TableDiff{Source: tt.sourceTable(), Target: tt.targetTable()}
+	if err := tt.prepareColumns(); err != nil {
+		diff.Error = err.Error()
+		return diff
+	}
+	live := readMySQLTableColumns(tt.Target, tt.targetTable())
+	if len(live) <= 0 {
+		diff.Missing = true
+		return diff
+	}
+	td := tt.tableDefinition()
+	diff.Columns = diffColumns(td.Columns, live)
+	diff.Indexes = diffIndexes(td, readMySQLIndexes(tt.Target, tt.targetTable()))
+	return diff
+}
+
+// jsonOutput - writer of a JSON report on stdout. the diagnostics printed until restore
+// is called go to stderr, leaving stdout to the JSON
+func jsonOutput() (io.Writer, func()) {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	return stdout, func() {
+		os.Stdout = stdout
+	}
+}
+
+// DiffSchema compares the translated source tables with the target tables,
+// written to out as text or JSON
+func DiffSchema(out io.Writer, asJSON bool) []TableDiff {
+	settings := GetConfigure(ConfigPath)
+
+	schemas := make([]string, 0, len(settings.Targets))
+	for schema := range settings.Targets {
+		schemas = append(schemas, schema)
+	}
+	sort.Strings(schemas)
+
+	diffs := make([]TableDiff, 0)
+	for _, schema := range schemas {
+		// open and close source
+		source, _ := OpenConnection(settings.Connectors[KEY_CNX_SOURCE], schema)
+		defer source.Close()
+		// open and close target
+		target, _ := OpenConnection(settings.Connectors[KEY_CNX_TARGET], settings.TargetDatabase(schema))
+		defer target.Close()
+
+		for _, task := range expandTargets(source, settings.Targets[schema], settings.IndexColumns) {
+			task = settings.Resolve(schema, task)
+			tt := TransferTask{Source: source, Target: target, Setting: task}
+			diffs = append(diffs, tt.diffTable())
+		}
+	}
+
+	if asJSON {
+		contents, _ := json.MarshalIndent(diffs, "", "  ")
+		fmt.Fprintln(out, string(contents))
+	} else {
+		for _, d := range diffs {
+			d.Print(out)
+		}
+	}
+	return diffs
+}
+
+// Print prints the differences of the table, + on the source only, - on the target only, ~ changed
+func (d TableDiff) Print(out io.Writer) {
+	marks := map[string]string{DiffAdded: "+", DiffRemoved: "-", DiffChanged: "~"}
+	fmt.Fprintf(out, "TABLE %s -> %s\n", d.Source, d.Target)
+	if 0 < len(d.Error) {
+		fmt.Fprintf(out, "  ERROR %s\n", d.Error)
+	} else if d.Missing {
+		fmt.Fprintf(out, "  missing on the target\n")
+	} else if d.Empty() {
+		fmt.Fprintf(out, "  no difference\n")
+	}
+	for _, c := range d.Columns {
+		switch c.Change {
+		case DiffAdded:
+			fmt.Fprintf(out, "  %s `%s` %s\n", marks[c.Change], c.Column, c.Source)
+		case DiffRemoved:
+			fmt.Fprintf(out, "  %s `%s` %s\n", marks[c.Change], c.Column, c.Target)
+		default:
+			fmt.Fprintf(out, "  %s `%s` %s\n", marks[c.Change], c.Column, strings.Join(c.Details, ", "))
+		}
+	}
+	for _, i := range d.Indexes {
+		switch i.Change {
+		case DiffAdded:
+			fmt.Fprintf(out, "  %s index %s %s\n", marks[i.Change], i.Index, i.Source)
+		case DiffRemoved:
+			fmt.Fprintf(out, "  %s index %s %s\n", marks[i.Change], i.Index, i.Target)
+		default:
+			fmt.Fprintf(out, "  %s index %s %s -> %s\n", marks[i.Change], i.Index, i.Source, i.Target)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiffColumns(t *testing.T) {
+	expected := []ColumnDefinition{
+		{Name: "id", DataType: "int", Nullable: false},
+		{Name: "Campaign name", DataType: "varchar(50)", Nullable: true},
+		{Name: "Planned cost_usd", DataType: "decimal(18,2)", Nullable: true},
+		{Name: "Date", DataType: "datetime(6)", Nullable: false},
+	}
+	live := []ColumnDefinition{
+		{Name: "ID", DataType: "int(11)", Nullable: false},
+		{Name: "Campaign name", DataType: "varchar(40)", Nullable: true},
+		{Name: "Date", DataType: "datetime(6)", Nullable: true},
+		{Name: "legacy_flag", DataType: "tinyint(1)", Nullable: true},
+	}
+
+	diffs := diffColumns(expected, live)
+	expects := []ColumnDiff{
+		{Column: "Campaign name", Change: DiffChanged, Details: []string{"type varchar(50) -> varchar(40)"}},
+		{Column: "Planned cost_usd", Change: DiffAdded},
+		{Column: "Date", Change: DiffChanged, Details: []string{"NOT NULL -> NULL"}},
+		{Column: "legacy_flag", Change: DiffRemoved},
+	}
+	if len(diffs) != len(expects) {
+		t.Fatalf("expected %d differences but %v", len(expects), diffs)
+	}
+	for i, e := range expects {
+		d := diffs[i]
+		if d.Column != e.Column || d.Change != e.Change || strings.Join(d.Details, ";") != strings.Join(e.Details, ";") {
+			t.Errorf("[%d] expected %v but %v", i, e, d)
+		}
+	}
+
+	if !matchTableColumns(expected[:1], live[:1]) {
+		t.Error("int and int(11) expected to match")
+	}
+}
+
+func TestDiffIndexes(t *testing.T) {
+	td := TableDefinition{
+		PrimaryKey: []string{"id"},
+		Indexes: []IndexDefinition{
+			{Name: "ix_campaign", Columns: []string{"Campaign name"}},
+			{Name: WatermarkIndexName, Columns: []string{"Date"}},
+		},
+	}
+	live := []IndexDefinition{
+		{Name: "PRIMARY", Unique: true, Columns: []string{"id"}},
+		{Name: "ix_campaign", Unique: true, Columns: []string{"Campaign name"}},
+		{Name: "ix_manual", Columns: []string{"Country"}},
+	}
+
+	diffs := diffIndexes(td, live)
+	expects := []IndexDiff{
+		{Index: "ix_campaign", Change: DiffChanged, Source: "KEY (Campaign name)", Target: "UNIQUE KEY (Campaign name)"},
+		{Index: WatermarkIndexName, Change: DiffAdded, Source: "KEY (Date)"},
+		{Index: "ix_manual", Change: DiffRemoved, Target: "KEY (Country)"},
+	}
+	if len(diffs) != len(expects) {
+		t.Fatalf("expected %d differences but %v", len(expects), diffs)
+	}
+	for i, e := range expects {
+		if diffs[i] != e {
+			t.Errorf("[%d] expected %v but %v", i, e, diffs[i])
+		}
+	}
+
+	contents, err := json.Marshal(TableDiff{Source: "[dbo].[t]", Target: "t", Indexes: diffs[1:2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"source":"[dbo].[t]","target":"t","indexes":[{"index":"ix_watermark","change":"added","source":"KEY (Date)"}]}`
+	if string(contents) != expect {
+		t.Errorf("expected %s but %s", expect, contents)
+	}
+}
+
+func TestDiffSchemaJSON(t *testing.T) {
+	// sp_columns row, a column of an unknown type warned about while diffing
+	column := []driver.Value{"Cheil", "dbo", "orders", "id", int64(4), "int", int64(10), int64(4), int64(0), int64(10), int64(0), nil, nil}
+	unknown := []driver.Value{"Cheil", "dbo", "orders", "shape", int64(-4), "geography", int64(0), int64(0), int64(0), int64(10), int64(1), nil, nil}
+	sql.Register("fake_diff_source", fakeDriver{"EXEC sp_columns @table_name=@p1, @table_owner=@p2": {
+		columns: []string{"TABLE_QUALIFIER", "TABLE_OWNER", "TABLE_NAME", "COLUMN_NAME", "DATA_TYPE", "TYPE_NAME", "PRECISION",
+			"LENGTH", "SCALE", "RADIX", "NULLABLE", "REMARKS", "COLUMN_DEF"},
+		values: [][]driver.Value{column, unknown},
+	}})
+	sql.Register("fake_diff_target", fakeDriver{})
+	ServiceConfig = &Settings{
+		Connectors: map[string]ConnectionSetting{
+			KEY_CNX_SOURCE: {Driver: "fake_diff_source"},
+			KEY_CNX_TARGET: {Driver: "fake_diff_target"},
+		},
+		Targets: map[string][]TableTransferSetting{"Cheil": {{Name: "orders", Index: "id"}}},
+	}
+	defer func() { ServiceConfig = nil }()
+
+	captured, err := ioutil.TempFile("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(captured.Name())
+	stdout := os.Stdout
+	os.Stdout = captured
+	RunSchema("diff", "--json")
+	os.Stdout = stdout
+	captured.Close()
+
+	contents, err := ioutil.ReadFile(captured.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diffs []TableDiff
+	if err := json.Unmarshal(contents, &diffs); err != nil {
+		t.Fatalf("stdout expected to hold the JSON only but %s: %s", err, contents)
+	}
+	if len(diffs) != 1 || !diffs[0].Missing || os.Stdout != stdout {
+		t.Errorf("unexpected %v", diffs)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// CatalogTable - table listed in the source catalog
+type CatalogTable struct {
+	Owner string
+	Name  string
+}
+
+// isTablePattern - whether the table name of the setting is a selector,
+// glob(`Cleansed_*`), regular expression(`/^Cleansed_.+$/`) or exclusion(`!tmp_*`)
+func isTablePattern(name string) bool {
+	return strings.HasPrefix(name, "!") ||
+		strings.HasPrefix(name, "/") ||
+		strings.ContainsAny(name, "*?[")
+}
+
+// matchPattern - match the name to a glob or /regular expression/, case-insensitively
+func matchPattern(pattern string, name string) bool {
+	if 2 < len(pattern) && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+		if err != nil {
+			fmt.Printf("invalid pattern %s: %s\n", pattern, err.Error())
+			return false
+		}
+		return re.MatchString(name)
+	}
+	matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return matched
+}
+
+// matchAny - whether the name matches any of the patterns
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// listMSSQLTables - base tables in the source catalog
+func listMSSQLTables(source *sql.DB) []CatalogTable {
+	query := `SELECT TABLE_SCHEMA, TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE='BASE TABLE' ORDER BY TABLE_SCHEMA, TABLE_NAME`
+	rows, err := queryFetchAll(source, query)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil
+	}
+	tables := make([]CatalogTable, len(rows))
+	for i, row := range rows {
+		tables[i] = CatalogTable{Owner: row[0].(string), Name: row[1].(string)}
+	}
+	return tables
+}
+
+// selectTables - explicit transfers followed by the catalog tables matching selectors.
+// a discovered table copies the settings of the first matching selector
+func selectTables(catalog []CatalogTable, transfers []TableTransferSetting) ([]TableTransferSetting, []TableTransferSetting) {
+	explicit := make([]TableTransferSetting, 0, len(transfers))
+	selectors := make([]TableTransferSetting, 0)
+	excludes := make([]string, 0)
+	for _, t := range transfers {
+		if !isTablePattern(t.Name) {
+			explicit = append(explicit, t)
+		} else if strings.HasPrefix(t.Name, "!") {
+			excludes = append(excludes, t.Name[1:])
+		} else {
+			selectors = append(selectors, t)
+		}
+	}
+
+	discovered := make([]TableTransferSetting, 0)
+	for _, table := range catalog {
+		if matchAny(excludes, table.Name) {
+			continue
+		}
+		listed := false
+		for _, t := range explicit {
+			if strings.EqualFold(t.Name, table.Name) && strings.EqualFold(t.Owner(), table.Owner) {
+				listed = true
+				break
+			}
+		}
+		if listed {
+			continue
+		}
+		for _, s := range selectors {
+			if len(s.SourceSchema) <= 0 || strings.EqualFold(s.Owner(), table.Owner) {
+				if matchPattern(s.Name, table.Name) {
+					s.Name = table.Name
+					s.SourceSchema = table.Owner
+					discovered = append(discovered, s)
+					break
+				}
+			}
+		}
+	}
+	return explicit, discovered
+}
+
+// defaultIndex - first of the index column candidates found in the columns
+func defaultIndex(columns []ColumnDefinition, candidates []string) string {
+	for _, c := range candidates {
+		for _, col := range columns {
+			if strings.EqualFold(col.Name, c) {
+				return col.Name
+			}
+		}
+	}
+	return ""
+}
+
+// expandTargets - expand table selectors against the source catalog and
+// fill the missing index columns by the index column rule
+func expandTargets(source *sql.DB, transfers []TableTransferSetting, indexColumns []string) []TableTransferSetting {
+	hasPattern := false
+	for _, t := range transfers {
+		hasPattern = hasPattern || isTablePattern(t.Name)
+	}
+
+	tables := transfers
+	if hasPattern {
+		explicit, discovered := selectTables(listMSSQLTables(source), transfers)
+		for _, t := range discovered {
+			fmt.Printf("  discovered %s.%s\n", t.Owner(), t.Name)
+		}
+		tables = append(explicit, discovered...)
+	}
+
+	rets := make([]TableTransferSetting, 0, len(tables))
+	for _, t := range tables {
+		if len(t.Index) <= 0 {
+			columns := readMSSQLTableColumns(source, t.Owner(), t.Name)
+			if t.Index = defaultIndex(columns, indexColumns); len(t.Index) <= 0 {
+				fmt.Printf("  skip %s.%s, no index column of %v\n", t.Owner(), t.Name, indexColumns)
+				continue
+			}
+		}
+		rets = append(rets, t)
+	}
+	return rets
+}
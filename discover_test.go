@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	samples := []struct {
+		Pattern string
+		Name    string
+		Expect  bool
+	}{
+		{"Cleansed_*", "Cleansed_Dataset", true},
+		{"cleansed_*", "Cleansed_Dataset", true},
+		{"Cleansed_*", "Raw_Dataset", false},
+		{"tmp_?", "tmp_1", true},
+		{"/^(raw|cleansed)_/", "Cleansed_Dataset", true},
+		{"/^raw_/", "Cleansed_Dataset", false},
+	}
+	for i, s := range samples {
+		if matched := matchPattern(s.Pattern, s.Name); matched != s.Expect {
+			t.Errorf("[%d] %s ~ %s expected %v", i, s.Pattern, s.Name, s.Expect)
+		}
+	}
+}
+
+func TestSelectTables(t *testing.T) {
+	catalog := []CatalogTable{
+		{"dbo", "Cleansed_Dataset"},
+		{"dbo", "Cleansed_Archive"},
+		{"dbo", "Cleansed_tmp"},
+		{"dbo", "tmp_Cleansed"},
+		{"sales", "Cleansed_Orders"},
+		{"dbo", "Users"},
+	}
+	transfers := []TableTransferSetting{
+		{Name: "Cleansed_Dataset", Index: "reg_date"},
+		{Name: "Cleansed_*", Where: "1=1"},
+		{Name: "!*tmp*"},
+	}
+
+	explicit, discovered := selectTables(catalog, transfers)
+	if len(explicit) != 1 || explicit[0].Index != "reg_date" {
+		t.Errorf("explicit expected only Cleansed_Dataset but %v", explicit)
+	}
+	expects := []CatalogTable{{"dbo", "Cleansed_Archive"}, {"sales", "Cleansed_Orders"}}
+	if len(discovered) != len(expects) {
+		t.Fatalf("expected %d discovered but %v", len(expects), discovered)
+	}
+	for i, d := range discovered {
+		if d.Owner() != expects[i].Owner || d.Name != expects[i].Name {
+			t.Errorf("[%d] expected %v but %s.%s", i, expects[i], d.Owner(), d.Name)
+		}
+		if d.Where != "1=1" {
+			t.Errorf("[%d] selector settings not copied", i)
+		}
+	}
+}
+
+func TestDefaultIndex(t *testing.T) {
+	columns := []ColumnDefinition{
+		{Name: "id", DataType: "int", Nullable: false},
+		{Name: "Reg_Date", DataType: "datetime", Nullable: true},
+		{Name: "insert_dt", DataType: "datetime", Nullable: true},
+	}
+	if index := defaultIndex(columns, []string{"insert_dt", "reg_date"}); index != "insert_dt" {
+		t.Errorf("expected insert_dt but %s", index)
+	}
+	if index := defaultIndex(columns, []string{"upd_dt", "reg_date"}); index != "Reg_Date" {
+		t.Errorf("expected Reg_Date but %s", index)
+	}
+	if index := defaultIndex(columns, []string{"upd_dt"}); index != "" {
+		t.Errorf("expected none but %s", index)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	mysqlKeyLength       = 3072           // longest InnoDB index key in bytes
+	mysqlIdentifierLimit = 64             // longest MySQL identifier
+	WatermarkIndexName   = "ix_watermark" // index on the watermark column
+	IdentityIndexName    = "ix_identity"  // index on the AUTO_INCREMENT column
+	PrimaryIndexName     = "ix_primary"   // index on the primary key columns too long to enforce
+)
+
+// IndexDefinition - index on the target table
+type IndexDefinition struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// readMSSQLPrimaryKey - primary key columns of the source table in key order
+func readMSSQLPrimaryKey(db *sql.DB, owner string, table string) []string {
+	rows, err := queryFetchAll(db, "EXEC sp_pkeys @table_name=@p1, @table_owner=@p2", table, owner)
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil
+	}
+	columns := make([]string, len(rows))
+	for _, row := range rows {
+		// KEY_SEQ starts from 1
+		if seq := int(columnInt(row[4])); 0 < seq && seq <= len(columns) {
+			columns[seq-1] = row[3].(string)
+		}
+	}
+	return columns
+}
+
+// readMSSQLIndexes - enabled non-clustered, non primary key indexes of the source table
+func readMSSQLIndexes(db *sql.DB, owner string, table string) []IndexDefinition {
+	query := `SELECT i.name, i.is_unique, c.name, i.has_filter, i.filter_definition
+	FROM sys.indexes i
+	JOIN sys.index_columns ic ON ic.object_id=i.object_id AND ic.index_id=i.index_id
+	JOIN sys.columns c ON c.object_id=ic.object_id AND c.column_id=ic.column_id
+	WHERE i.object_id=OBJECT_ID(@p1) AND i.type=2 AND i.is_primary_key=0 AND i.is_hypothetical=0 AND i.is_disabled=0 AND ic.is_included_column=0
+	ORDER BY i.name, ic.key_ordinal`
+	rows, err := queryFetchAll(db, query, fmt.Sprintf("%s.%s", quoteMSSQL(owner), quoteMSSQL(table)))
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil
+	}
+	return buildMSSQLIndexes(rows)
+}
+
+// buildMSSQLIndexes - indexes of the sys.indexes rows, a row per column.
+// MySQL has no filtered index, a filtered unique index is copied without uniqueness
+func buildMSSQLIndexes(rows [][]interface{}) []IndexDefinition {
+	indexes := make([]IndexDefinition, 0)
+	for _, row := range rows {
+		name := row[0].(string)
+		if n := len(indexes); n <= 0 || indexes[n-1].Name != name {
+			index := IndexDefinition{Name: name, Unique: row[1].(bool)}
+			if filtered, _ := row[3].(bool); filtered && index.Unique {
+				fmt.Printf("    WARN unique index %s is filtered %s, indexed without uniqueness\n", name, transformString(row[4]))
+				index.Unique = false
+			}
+			indexes = append(indexes, index)
+		}
+		last := &indexes[len(indexes)-1]
+		last.Columns = append(last.Columns, row[2].(string))
+	}
+	return indexes
+}
+
+// targetNames - target names of the source columns, false when any is not copied
+func targetNames(mappings []ColumnMapping, names []string) ([]string, bool) {
+	targets := make([]string, len(names))
+	for i, name := range names {
+		found := false
+		for _, m := range mappings {
+			if strings.EqualFold(m.Source.Name, name) {
+				targets[i], found = m.Target.Name, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return targets, true
+}
+
+// mapIndexes - primary key and indexes named by the target columns,
+// keys on columns not copied are dropped
+func mapIndexes(mappings []ColumnMapping, primary []string, indexes []IndexDefinition) ([]string, []IndexDefinition) {
+	pk, ok := targetNames(mappings, primary)
+	if !ok {
+		fmt.Printf("    primary key (%s) is not copied\n", strings.Join(primary, ","))
+		pk = nil
+	}
+	mapped := make([]IndexDefinition, 0, len(indexes))
+	for _, index := range indexes {
+		columns, ok := targetNames(mappings, index.Columns)
+		if !ok {
+			fmt.Printf("    index %s (%s) is not copied\n", index.Name, strings.Join(index.Columns, ","))
+			continue
+		}
+		mapped = append(mapped, IndexDefinition{Name: truncateName(index.Name), Unique: index.Unique, Columns: columns})
+	}
+	return pk, mapped
+}
+
+// watermarkIndex - index on the watermark column, false when a key already leads with it
+func watermarkIndex(column string, primary []string, indexes []IndexDefinition) (IndexDefinition, bool) {
+	return leadingIndex(WatermarkIndexName, column, primary, indexes)
+}
+
+// leadingIndex - index on the column, false when a key already leads with it
+func leadingIndex(name string, column string, primary []string, indexes []IndexDefinition) (IndexDefinition, bool) {
+	if len(column) <= 0 || (0 < len(primary) && strings.EqualFold(primary[0], column)) {
+		return IndexDefinition{}, false
+	}
+	for _, index := range indexes {
+		if strings.EqualFold(index.Columns[0], column) {
+			return IndexDefinition{}, false
+		}
+	}
+	return IndexDefinition{Name: name, Columns: []string{column}}, true
+}
+
+// truncateName - identifier cut to the MySQL limit
+func truncateName(name string) string {
+	if mysqlIdentifierLimit < len(name) {
+		return name[:mysqlIdentifierLimit]
+	}
+	return name
+}
+
+// charsetBytes - longest character in bytes of the charset
+func charsetBytes(charset string) int {
+	switch strings.ToLower(charset) {
+	case "latin1", "ascii", "binary":
+		return 1
+	case "utf8", "utf8mb3":
+		return 3
+	}
+	return 4
+}
+
+var typeLengthPattern = regexp.MustCompile(`^(\w+)\((\d+)\)`)
+
+// keyLength - bytes of the column in an index key, bytes per unit of a prefix length
+// (0 when the column can not be prefixed) and whether a prefix is required, as on text and blob columns
+func keyLength(datatype string, charset string) (int, int, bool) {
+	datatype = strings.ToLower(datatype)
+	if strings.HasSuffix(datatype, "text") {
+		return 0, charsetBytes(charset), true
+	} else if strings.HasSuffix(datatype, "blob") {
+		return 0, 1, true
+	}
+	if m := typeLengthPattern.FindStringSubmatch(datatype); m != nil {
+		length, _ := strconv.Atoi(m[2])
+		switch m[1] {
+		case "char", "varchar":
+			return length * charsetBytes(charset), charsetBytes(charset), false
+		case "binary", "varbinary":
+			return length, 1, false
+		}
+	}
+	return 8, 0, false
+}
+
+// keyPrefixes - prefix lengths of the key columns keeping the key within the MySQL key length limit,
+// 0 on the columns indexed in full
+func keyPrefixes(names []string, columns []ColumnDefinition, charset string) []int {
+	type keyPart struct {
+		Bytes  int
+		Unit   int
+		Prefix bool
+	}
+	parts := make([]keyPart, len(names))
+	fixed, variables := 0, 0
+	for i, name := range names {
+		parts[i] = keyPart{Bytes: 8}
+		for _, col := range columns {
+			if strings.EqualFold(col.Name, name) {
+				parts[i].Bytes, parts[i].Unit, parts[i].Prefix = keyLength(col.DataType, charset)
+				break
+			}
+		}
+		if 0 < parts[i].Unit {
+			variables += 1
+		} else {
+			fixed += parts[i].Bytes
+		}
+	}
+
+	// share the remaining key length among the variable length columns
+	share := 0
+	if 0 < variables {
+		share = (mysqlKeyLength - fixed) / variables
+	}
+	prefixes := make([]int, len(parts))
+	for i, p := range parts {
+		if 0 < p.Unit && (p.Prefix || share < p.Bytes) {
+			prefixes[i] = share / p.Unit
+			if prefixes[i] <= 0 {
+				prefixes[i] = 1
+			}
+		}
+	}
+	return prefixes
+}
+
+// needsPrefix - whether the key is indexed on a prefix of any column
+func needsPrefix(names []string, columns []ColumnDefinition, charset string) bool {
+	for _, prefix := range keyPrefixes(names, columns, charset) {
+		if 0 < prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// keyParts - index key parts of the columns, with prefix lengths keeping the key
+// within the MySQL key length limit when prefixed
+func keyParts(names []string, columns []ColumnDefinition, charset string, prefixed bool) []string {
+	prefixes := keyPrefixes(names, columns, charset)
+	rets := make([]string, len(names))
+	for i, name := range names {
+		rets[i] = quoteMySQL(name)
+		if prefixed && 0 < prefixes[i] {
+			rets[i] = fmt.Sprintf("%s(%d)", rets[i], prefixes[i])
+		}
+	}
+	return rets
+}
+
+// demoteLongKeys - primary and unique keys that only fit on column prefixes, as plain indexes.
+// a prefixed unique key enforces the prefix only, and the upsert would overwrite the rows sharing one
+func demoteLongKeys(primary []string, indexes []IndexDefinition, columns []ColumnDefinition, charset string) ([]string, []IndexDefinition) {
+	rets := make([]IndexDefinition, 0, len(indexes)+1)
+	if 0 < len(primary) && needsPrefix(primary, columns, charset) {
+		fmt.Printf("    WARN primary key (%s) is too long to enforce, indexed as %s and rows are not upserted\n",
+			strings.Join(primary, ","), PrimaryIndexName)
+		rets = append(rets, IndexDefinition{Name: PrimaryIndexName, Columns: primary})
+		primary = nil
+	}
+	for _, index := range indexes {
+		if index.Unique && needsPrefix(index.Columns, columns, charset) {
+			fmt.Printf("    WARN unique key %s (%s) is too long to enforce, indexed without uniqueness\n",
+				index.Name, strings.Join(index.Columns, ","))
+			index.Unique = false
+		}
+		rets = append(rets, index)
+	}
+	return primary, rets
+}
+
+// keyQuery - key clause of the index, unique keys are never prefixed
+func (td TableDefinition) keyQuery(index IndexDefinition) string {
+	kind := "KEY"
+	if index.Unique {
+		kind = "UNIQUE KEY"
+	}
+	return fmt.Sprintf("%s %s (%s)", kind, quoteMySQL(index.Name),
+		strings.Join(keyParts(index.Columns, td.Columns, td.Charset, !index.Unique), ","))
+}
+
+// primaryKeyQuery - primary key clause, never prefixed
+func (td TableDefinition) primaryKeyQuery() string {
+	return fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keyParts(td.PrimaryKey, td.Columns, td.Charset, false), ","))
+}
+
+// readMySQLIndexes - indexes on the target table in key order, PRIMARY for the primary key
+func readMySQLIndexes(db *sql.DB, table string) []IndexDefinition {
+	rows, err := queryFetchAll(db, fmt.Sprintf("SHOW INDEX FROM %s", quoteMySQL(table)))
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil
+	}
+	indexes := make([]IndexDefinition, 0)
+	for _, row := range rows {
+		// Non_unique, Key_name, Seq_in_index, Column_name
+		name := transformString(row[2])
+		if n := len(indexes); n <= 0 || indexes[n-1].Name != name {
+			indexes = append(indexes, IndexDefinition{Name: name, Unique: transformString(row[1]) == "0"})
+		}
+		last := &indexes[len(indexes)-1]
+		last.Columns = append(last.Columns, transformString(row[4]))
+	}
+	return indexes
+}
+
+// readMySQLIndexNames - index names on the target table, PRIMARY for the primary key
+func readMySQLIndexNames(db *sql.DB, table string) []string {
+	names := make([]string, 0)
+	for _, index := range readMySQLIndexes(db, table) {
+		names = append(names, index.Name)
+	}
+	return names
+}
+
+// addMissingIndexes - add the keys of the definition missing on an existing table
+func addMissingIndexes(db *sql.DB, td TableDefinition) {
+	existing := readMySQLIndexNames(db, td.Name)
+	clauses := make([]string, 0)
+	if 0 < len(td.PrimaryKey) && !containsName(existing, "PRIMARY") {
+		clauses = append(clauses, "ADD "+td.primaryKeyQuery())
+	}
+	for _, index := range td.Indexes {
+		if !containsName(existing, index.Name) {
+			clauses = append(clauses, "ADD "+td.keyQuery(index))
+		}
+	}
+	if len(clauses) <= 0 {
+		return
+	}
+	query := fmt.Sprintf("ALTER TABLE %s %s", quoteMySQL(td.Name), strings.Join(clauses, ","))
+	fmt.Println(query)
+	if _, err := db.Exec(query); err != nil {
+		fmt.Println(err.Error())
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyParts(t *testing.T) {
+	columns := []ColumnDefinition{
+		{Name: "id", DataType: "int"},
+		{Name: "code", DataType: "varchar(50)"},
+		{Name: "url", DataType: "varchar(2000)"},
+		{Name: "memo", DataType: "longtext"},
+		{Name: "hash", DataType: "binary(16)"},
+	}
+	samples := []struct {
+		Names  []string
+		Expect string
+	}{
+		{[]string{"id"}, "`id`"},
+		{[]string{"id", "code"}, "`id`,`code`"},
+		// 3072 - 8 bytes left for url in utf8mb4
+		{[]string{"id", "url"}, "`id`,`url`(766)"},
+		{[]string{"code", "url"}, "`code`,`url`(384)"},
+		{[]string{"memo"}, "`memo`(768)"},
+		{[]string{"hash", "code"}, "`hash`,`code`"},
+	}
+	for i, s := range samples {
+		if parts := strings.Join(keyParts(s.Names, columns, "utf8mb4", true), ","); parts != s.Expect {
+			t.Errorf("[%d] expected %s but %s", i, s.Expect, parts)
+		}
+	}
+	if parts := strings.Join(keyParts([]string{"url"}, columns, "latin1", true), ","); parts != "`url`" {
+		t.Errorf("latin1 url expected without prefix but %s", parts)
+	}
+	if parts := strings.Join(keyParts([]string{"id", "url"}, columns, "utf8mb4", false), ","); parts != "`id`,`url`" {
+		t.Errorf("unique key expected without prefix but %s", parts)
+	}
+}
+
+func TestDemoteLongKeys(t *testing.T) {
+	columns := []ColumnDefinition{
+		{Name: "id", DataType: "int"},
+		{Name: "code", DataType: "varchar(50)"},
+		{Name: "url", DataType: "varchar(2000)"},
+	}
+	indexes := []IndexDefinition{
+		{Name: "UX_Code", Unique: true, Columns: []string{"code"}},
+		{Name: "UX_Url", Unique: true, Columns: []string{"id", "url"}},
+		{Name: "IX_Url", Columns: []string{"url"}},
+	}
+	pk, demoted := demoteLongKeys([]string{"id"}, indexes, columns, "utf8mb4")
+	if len(pk) != 1 || len(demoted) != 3 || !demoted[0].Unique || demoted[1].Unique {
+		t.Errorf("only UX_Url expected demoted but %v %v", pk, demoted)
+	}
+	pk, demoted = demoteLongKeys([]string{"url"}, nil, columns, "utf8mb4")
+	if pk != nil || len(demoted) != 1 || demoted[0].Name != PrimaryIndexName || demoted[0].Unique {
+		t.Errorf("primary key expected demoted but %v %v", pk, demoted)
+	}
+	if pk, _ = demoteLongKeys([]string{"url"}, nil, columns, "latin1"); len(pk) != 1 {
+		t.Errorf("latin1 primary key expected kept")
+	}
+}
+
+func TestMapIndexes(t *testing.T) {
+	mappings := mapColumns([]ColumnDefinition{
+		{Name: "id", DataType: "int"},
+		{Name: "Campaign name", DataType: "varchar(50)"},
+		{Name: "insert_dt", DataType: "datetime"},
+	}, ColumnSelection{Rename: map[string]string{"Campaign name": "campaign_name"}})
+
+	pk, indexes := mapIndexes(mappings, []string{"id"}, []IndexDefinition{
+		{Name: "IX_Campaign", Columns: []string{"Campaign name", "insert_dt"}},
+		{Name: "IX_Audit", Unique: true, Columns: []string{"audit_user"}},
+		{Name: strings.Repeat("x", 70), Columns: []string{"id"}},
+	})
+	if len(pk) != 1 || pk[0] != "id" {
+		t.Errorf("primary key expected id but %v", pk)
+	}
+	if len(indexes) != 2 || indexes[0].Columns[0] != "campaign_name" {
+		t.Errorf("indexes expected on target names but %v", indexes)
+	}
+	if len(indexes[1].Name) != mysqlIdentifierLimit {
+		t.Errorf("long index name not truncated, %d", len(indexes[1].Name))
+	}
+
+	if pk, _ = mapIndexes(mappings, []string{"id", "audit_user"}, nil); pk != nil {
+		t.Errorf("primary key on excluded column expected to drop but %v", pk)
+	}
+
+	if _, missing := watermarkIndex("insert_dt", pk, indexes); !missing {
+		t.Error("watermark index expected")
+	}
+	if _, missing := watermarkIndex("campaign_name", pk, indexes); missing {
+		t.Error("watermark index already covered")
+	}
+}
+
+func TestBuildMSSQLIndexes(t *testing.T) {
+	indexes := buildMSSQLIndexes([][]interface{}{
+		{"IX_Campaign", false, "campaign", false, nil},
+		{"IX_Campaign", false, "insert_dt", false, nil},
+		{"UX_Code", true, "code", true, "([code] IS NOT NULL)"},
+		{"UX_Id", true, "id", false, nil},
+	})
+	if len(indexes) != 3 || len(indexes[0].Columns) != 2 {
+		t.Fatalf("indexes expected grouped by name but %v", indexes)
+	}
+	if indexes[1].Unique {
+		t.Error("filtered unique index expected without uniqueness")
+	}
+	if !indexes[2].Unique {
+		t.Error("unique index expected kept")
+	}
+}
+
+func TestCreateQueryKeys(t *testing.T) {
+	td := TableDefinition{
+		Name: "Cleansed_Dataset",
+		Columns: []ColumnDefinition{
+			{Name: "id", DataType: "int", Nullable: false},
+			{Name: "memo", DataType: "text", Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes:    []IndexDefinition{{Name: "IX_Memo", Columns: []string{"memo"}}, {Name: "UX_Id", Unique: true, Columns: []string{"id", "memo"}}},
+	}
+	// the unique key on text is demoted to a plain key
+	td.PrimaryKey, td.Indexes = demoteLongKeys(td.PrimaryKey, td.Indexes, td.Columns, "utf8mb4")
+	expect := "CREATE TABLE IF NOT EXISTS `Cleansed_Dataset` (`id` int NOT NULL,`memo` text," +
+		"PRIMARY KEY (`id`),KEY `IX_Memo` (`memo`(768)),KEY `UX_Id` (`id`,`memo`(766)))"
+	if q := td.CreateQuery(); q != expect {
+		t.Errorf("expected %s but %s", expect, q)
+	}
+}
@@ -145,7 +145,7 @@ func stopTheService(service *mgr.Service) {
 	}
 }
 
-func controlService(cmd string) {
+func controlService(cmd string, args ...string) {
 	manager, err := mgr.Connect()
 	errorCheck(err, -1, "can not connect the Service Manager")
 	defer manager.Disconnect()
@@ -184,7 +184,11 @@ func controlService(cmd string) {
 		case "tables":
 			RunTransferTables()
 		case "views":
-			RunTransferViews()
+			RunViews(args...)
+		case "plan":
+			RunPlan()
+		case "schema":
+			RunSchema(args...)
 		default:
 			log.Fatalf("invalid command : %s", cmd)
 			log.Fatal("Command must be in one of (debug | install | uninstall | start | stop | restart)")
@@ -199,6 +203,6 @@ func main() {
 		// service run mode
 		Run(false)
 	} else {
-		controlService(os.Args[1])
+		controlService(os.Args[1], os.Args[2:]...)
 	}
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ShadowTableSuffix  = "__shadow" // table a materialized view is loaded into
+	RetiredTableSuffix = "__old"    // table a materialized view replaces
+)
+
+// suffixedTableName - table name with the suffix, the name cut to keep the suffix within the identifier limit
+func suffixedTableName(name string, suffix string) string {
+	if mysqlIdentifierLimit < len(name)+len(suffix) {
+		name = name[:mysqlIdentifierLimit-len(suffix)]
+	}
+	return name + suffix
+}
+
+// swapQueries - statements replacing the target by the loaded shadow table.
+// a view of the name is dropped first, an existing table is swapped in a single rename
+func swapQueries(target string, shadow string, exists bool, isView bool) []string {
+	if isView {
+		return []string{
+			fmt.Sprintf("DROP VIEW IF EXISTS %s", quoteMySQL(target)),
+			fmt.Sprintf("RENAME TABLE %s TO %s", quoteMySQL(shadow), quoteMySQL(target)),
+		}
+	}
+	if !exists {
+		return []string{fmt.Sprintf("RENAME TABLE %s TO %s", quoteMySQL(shadow), quoteMySQL(target))}
+	}
+	retired := suffixedTableName(target, RetiredTableSuffix)
+	return []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteMySQL(retired)),
+		fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s",
+			quoteMySQL(target), quoteMySQL(retired), quoteMySQL(shadow), quoteMySQL(target)),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteMySQL(retired)),
+	}
+}
+
+// materializeView - copy the result of the source view into the table of the target view name.
+// the rows are loaded into a shadow table, which replaces the target only when every row is copied
+func (vt *ViewTask) materializeView(name string, target string, newViews map[string]string) ViewResult {
+	result := ViewResult{Name: name, Target: target, Status: ViewMaterialized}
+	fail := func(err error) ViewResult {
+		result.Status, result.Error = ViewFailed, err.Error()
+		return result
+	}
+
+	shadow := suffixedTableName(target, ShadowTableSuffix)
+	if _, err := vt.Target.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteMySQL(shadow))); err != nil {
+		return fail(err)
+	}
+	// the view is read as a table, every row on each run
+	setting := vt.Table
+	setting.Name, setting.SourceSchema, setting.Target, setting.Index, setting.Lookback = name, vt.viewOwner(name), shadow, "", ""
+	setting.Transforms = vt.Setting.Option(name).Transforms
+	tt := TransferTask{Source: vt.Source, Target: vt.Target, Setting: setting, Success: "", Zones: vt.Zones}
+	if err := tt.prepareColumns(); err != nil {
+		return fail(err)
+	}
+	if err := tt.duplicateTable(); err != nil {
+		return fail(err)
+	}
+	if len(readMySQLTableColumns(vt.Target, shadow)) <= 0 {
+		return fail(fmt.Errorf("shadow table %s is not created", shadow))
+	}
+	count, err := tt.copyRows()
+	if err != nil {
+		vt.Target.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteMySQL(shadow)))
+		return fail(err)
+	}
+	result.Rows = count
+
+	_, isView := newViews[target]
+	exists := !isView && 0 < len(readMySQLTableColumns(vt.Target, target))
+	queries := swapQueries(target, shadow, exists, isView)
+	for _, query := range queries {
+		if _, err := vt.Target.Exec(query); err != nil {
+			result.Translated = strings.Join(queries, ";\n")
+			return fail(err)
+		}
+	}
+	// a table now, not a view to replace or drop
+	delete(vt.Fingerprints, target)
+	return result
+}
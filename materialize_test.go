@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuffixedTableName(t *testing.T) {
+	if name := suffixedTableName("v_sales", ShadowTableSuffix); name != "v_sales__shadow" {
+		t.Errorf("unexpected %s", name)
+	}
+	long := strings.Repeat("v", 70)
+	name := suffixedTableName(long, ShadowTableSuffix)
+	if len(name) != mysqlIdentifierLimit || !strings.HasSuffix(name, ShadowTableSuffix) {
+		t.Errorf("expected cut to %d with the suffix but %s", mysqlIdentifierLimit, name)
+	}
+}
+
+func TestSwapQueries(t *testing.T) {
+	samples := []struct {
+		Exists bool
+		IsView bool
+		Expect []string
+	}{
+		{false, false, []string{"RENAME TABLE `v__shadow` TO `v`"}},
+		{false, true, []string{"DROP VIEW IF EXISTS `v`", "RENAME TABLE `v__shadow` TO `v`"}},
+		{true, false, []string{
+			"DROP TABLE IF EXISTS `v__old`",
+			"RENAME TABLE `v` TO `v__old`, `v__shadow` TO `v`",
+			"DROP TABLE IF EXISTS `v__old`",
+		}},
+	}
+	for i, s := range samples {
+		queries := swapQueries("v", "v__shadow", s.Exists, s.IsView)
+		if strings.Join(queries, ";") != strings.Join(s.Expect, ";") {
+			t.Errorf("[%d] expected %v but %v", i, s.Expect, queries)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	NamingKeep      = "keep"       // target names as the source names
+	NamingPercent   = "percent"    // % removed, the names of the tables replicated before the strategies
+	NamingStrip     = "strip"      // characters other than letters, digits, _, $ and spaces removed
+	NamingReplace   = "replace"    // characters other than letters, digits, _, $ and spaces replaced
+	NamingSnakeCase = "snake_case" // lower case words joined by _
+
+	DefaultNamingStrategy    = NamingPercent
+	DefaultNamingReplacement = "_"
+)
+
+// NamingSetting - normalization of the source column names on the target
+type NamingSetting struct {
+	Strategy    string `yaml:"strategy"`    // keep, percent(default), strip, replace or snake_case
+	Replacement string `yaml:"replacement"` // replacement of the unsafe characters on replace, _ when empty
+}
+
+// NameMap - target table(key) per source column(key) to target column name
+type NameMap map[string]map[string]string
+
+// Valid - whether the strategy is known
+func (n NamingSetting) Valid() bool {
+	switch n.Strategy {
+	case "", NamingKeep, NamingPercent, NamingStrip, NamingReplace, NamingSnakeCase:
+		return true
+	}
+	return false
+}
+
+// isSafeNameRune - whether the character is kept by strip and replace
+func isSafeNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$' || r == ' '
+}
+
+var (
+	snakeWordPattern  = regexp.MustCompile(`([\p{Ll}\p{N}])(\p{Lu})`)
+	snakeSplitPattern = regexp.MustCompile(`[^\p{L}\p{N}$]+`)
+)
+
+// Normalize - target name of the source name by the strategy
+func (n NamingSetting) Normalize(name string) string {
+	switch n.Strategy {
+	case NamingKeep:
+		return name
+	case NamingReplace:
+		replacement := n.Replacement
+		if len(replacement) <= 0 {
+			replacement = DefaultNamingReplacement
+		}
+		var sb strings.Builder
+		for _, r := range name {
+			if isSafeNameRune(r) {
+				sb.WriteRune(r)
+			} else {
+				sb.WriteString(replacement)
+			}
+		}
+		return strings.TrimSpace(sb.String())
+	case NamingSnakeCase:
+		name = snakeWordPattern.ReplaceAllString(name, "${1}_${2}")
+		name = snakeSplitPattern.ReplaceAllString(name, "_")
+		return strings.ToLower(strings.Trim(name, "_"))
+	case NamingStrip:
+		return strings.TrimSpace(strings.Map(func(r rune) rune {
+			if isSafeNameRune(r) {
+				return r
+			}
+			return -1
+		}, name))
+	}
+	// percent
+	return strings.TrimSpace(strings.ReplaceAll(name, "%", ""))
+}
+
+// nameColumns - normalize the target names not renamed explicitly,
+// fails when two columns end up with the same name on the target
+func nameColumns(mappings []ColumnMapping, rename map[string]string, naming NamingSetting) ([]ColumnMapping, error) {
+	if !naming.Valid() {
+		return nil, fmt.Errorf("unknown naming strategy %s", naming.Strategy)
+	}
+	for i, m := range mappings {
+		renamed := false
+		for from := range rename {
+			renamed = renamed || strings.EqualFold(from, m.Source.Name)
+		}
+		if !renamed {
+			mappings[i].Target.Name = naming.Normalize(m.Source.Name)
+		}
+	}
+
+	// MySQL column names are case-insensitive, up to 64 characters
+	for i, m := range mappings {
+		name := truncateName(m.Target.Name)
+		if len(name) <= 0 {
+			return nil, fmt.Errorf("column [%s] has no name on the target", m.Source.Name)
+		}
+		for _, other := range mappings[:i] {
+			if strings.EqualFold(truncateName(other.Target.Name), name) {
+				return nil, fmt.Errorf("columns [%s] and [%s] are both named `%s` on the target", other.Source.Name, m.Source.Name, name)
+			}
+		}
+		mappings[i].Target.Name = name
+	}
+	return mappings, nil
+}
+
+// Record - record the target names of the table
+func (nm NameMap) Record(table string, mappings []ColumnMapping) {
+	names := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		names[m.Source.Name] = m.Target.Name
+	}
+	nm[table] = names
+}
+
+// Columns - target names of the source columns over the tables of the database,
+// names mapped differently on tables are left out
+func (nm NameMap) Columns(database string) map[string]string {
+	columns := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for table, names := range nm {
+		if !strings.HasPrefix(strings.ToLower(table), strings.ToLower(database)+".") {
+			continue
+		}
+		for source, target := range names {
+			key := strings.ToLower(source)
+			if found, exists := columns[key]; exists && found != target {
+				ambiguous[key] = true
+			}
+			columns[key] = target
+		}
+	}
+	for key := range ambiguous {
+		delete(columns, key)
+	}
+	return columns
+}
+
+// GetNameMap - load the target names recorded on the path, empty when none
+func GetNameMap(path string) NameMap {
+	names := NameMap{}
+	if len(path) <= 0 {
+		return names
+	}
+	if err := LoadFromYaml(path, &names); err != nil && !os.IsNotExist(err) {
+		fmt.Println(err.Error())
+	}
+	return names
+}
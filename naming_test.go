@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+type NamingSample struct {
+	Strategy string
+	Name     string
+	Expect   string
+}
+
+func TestNormalizeName(t *testing.T) {
+	samples := []NamingSample{
+		{"", "Video watches at 25%", "Video watches at 25"},
+		{"", "Cost (usd)/day", "Cost (usd)/day"},
+		{NamingPercent, "Video watches at 25%", "Video watches at 25"},
+		{NamingStrip, "Planned CPM_usd", "Planned CPM_usd"},
+		{NamingStrip, "Cost (usd)/day", "Cost usdday"},
+		{NamingKeep, "Video watches at 25%", "Video watches at 25%"},
+		{NamingReplace, "Video watches at 25%", "Video watches at 25_"},
+		{NamingReplace, "Cost (usd)", "Cost _usd_"},
+		{NamingSnakeCase, "Video watches at 25%", "video_watches_at_25"},
+		{NamingSnakeCase, "CrawlMediaAccount", "crawl_media_account"},
+		{NamingSnakeCase, "Planned CPV_views_usd", "planned_cpv_views_usd"},
+		{NamingSnakeCase, "캠페인 이름", "캠페인_이름"},
+	}
+	for i, s := range samples {
+		if name := (NamingSetting{Strategy: s.Strategy}).Normalize(s.Name); name != s.Expect {
+			t.Errorf("[%d] %s of %s expected %s but %s", i, s.Strategy, s.Name, s.Expect, name)
+		}
+	}
+	if name := (NamingSetting{Strategy: NamingReplace, Replacement: "pct"}).Normalize("25%"); name != "25pct" {
+		t.Errorf("replacement expected 25pct but %s", name)
+	}
+}
+
+func TestNameColumns(t *testing.T) {
+	columns := []ColumnDefinition{
+		{Name: "Video watches at 25%"},
+		{Name: "Video watches at 25"},
+	}
+	// strip names both the same
+	_, err := nameColumns(mapColumns(columns, ColumnSelection{}), nil, NamingSetting{})
+	if err == nil || !strings.Contains(err.Error(), "[Video watches at 25%] and [Video watches at 25]") {
+		t.Errorf("collision expected but %v", err)
+	}
+
+	// renamed columns are not normalized
+	rename := map[string]string{"video watches at 25%": "video_watches_25_pct"}
+	mappings, err := nameColumns(mapColumns(columns, ColumnSelection{Rename: rename}), rename, NamingSetting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mappings[0].Target.Name != "video_watches_25_pct" || mappings[1].Target.Name != "Video watches at 25" {
+		t.Errorf("unexpected names %s, %s", mappings[0].Target.Name, mappings[1].Target.Name)
+	}
+
+	// collisions are case-insensitive
+	_, err = nameColumns(mapColumns([]ColumnDefinition{{Name: "CID"}, {Name: "cid"}}, ColumnSelection{}), nil, NamingSetting{Strategy: NamingKeep})
+	if err == nil {
+		t.Error("case-insensitive collision expected")
+	}
+	_, err = nameColumns(mapColumns(columns, ColumnSelection{}), nil, NamingSetting{Strategy: "camel"})
+	if err == nil {
+		t.Error("unknown strategy expected to fail")
+	}
+}
+
+func TestRenameViewColumns(t *testing.T) {
+	names := NameMap{}
+	names.Record("cheil.cleansed", []ColumnMapping{
+		{Source: ColumnDefinition{Name: "Video watches at 25%"}, Target: ColumnDefinition{Name: "video_watches_at_25"}},
+		{Source: ColumnDefinition{Name: "CID"}, Target: ColumnDefinition{Name: "cid"}},
+	})
+	names.Record("cheil.other", []ColumnMapping{
+		{Source: ColumnDefinition{Name: "CID"}, Target: ColumnDefinition{Name: "CID"}},
+	})
+	names.Record("sales.orders", []ColumnMapping{
+		{Source: ColumnDefinition{Name: "Date"}, Target: ColumnDefinition{Name: "order_date"}},
+	})
+
+	columns := names.Columns("cheil")
+	if _, exists := columns["cid"]; exists {
+		t.Error("CID named differently on tables expected to be left out")
+	}
+	if _, exists := columns["date"]; exists {
+		t.Error("Date of another database expected to be left out")
+	}
+
+	query := copyViewQuery("CREATE VIEW [dbo].[v] AS SELECT [Video watches at 25%], [CID], [Cost (usd)] FROM [dbo].[cleansed]")
+	expect := "CREATE VIEW `v` AS SELECT `video_watches_at_25`, `CID`, `Cost (usd)` FROM `cleansed`"
+	if q := renameViewColumns(query, columns, NamingSetting{}); q != expect {
+		t.Errorf("expected %s but %s", expect, q)
+	}
+	expect = "CREATE VIEW `v` AS SELECT `video_watches_at_25`, `CID`, `Cost usd` FROM `cleansed`"
+	if q := renameViewColumns(query, columns, NamingSetting{Strategy: NamingStrip}); q != expect {
+		t.Errorf("expected %s but %s", expect, q)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RunPlan prints what a table transfer would do, without writing anything
+func RunPlan() {
+	settings := GetConfigure(ConfigPath)
+	success := GetSuccessor(settings.Successor)
+
+	for schema, transfers := range settings.Targets {
+		// open and close source
+		source, _ := OpenConnection(settings.Connectors[KEY_CNX_SOURCE], schema)
+		defer source.Close()
+
+		fmt.Printf("DB %s -> %s\n", schema, settings.TargetDatabase(schema))
+		// expand table selectors
+		transfers = expandTargets(source, transfers, settings.IndexColumns)
+
+		for _, task := range transfers {
+			sc := success.Watermark(schema, task)
+			task = settings.Resolve(schema, task)
+			tt := TransferTask{Source: source, Setting: task, Success: sc}
+			tt.printPlan()
+		}
+	}
+}
+
+// printPlan prints the source, target, filters and columns of the task
+func (tt *TransferTask) printPlan() {
+	fmt.Printf("  TABLE %s -> %s\n", tt.sourceTable(), tt.targetTable())
+	fmt.Printf("    index %s, watermark %v\n", tt.Setting.Index, tt.Success)
+	if 0 < len(tt.Setting.Where) {
+		fmt.Printf("    where (%s)\n", tt.Setting.Where)
+	}
+	if from, rewound := tt.rewind(); rewound {
+		fmt.Printf("    lookback %s from %v\n", tt.Setting.Lookback, from)
+	}
+
+	if err := tt.prepareColumns(); err != nil {
+		fmt.Printf("    %s\n", err.Error())
+		return
+	}
+	for _, m := range tt.Columns {
+		notes := ""
+		if m.Overridden {
+			notes += " (override)"
+		}
+		if m.Transform != nil {
+			notes += fmt.Sprintf(" (%s)", m.Transform.Type)
+		}
+		if m.Target.Identity {
+			notes += " AUTO_INCREMENT"
+		}
+		if 0 < len(m.Target.Default) {
+			notes += " DEFAULT " + m.Target.Default
+		}
+		if m.Target.Computed {
+			notes += fmt.Sprintf(" AS (%s)", m.Target.Expression)
+		} else if m.Source.Computed {
+			notes += " (materialized)"
+		}
+		fmt.Printf("    - [%s] %s -> `%s` %s%s\n", m.Source.Name, m.Source.SourceType, m.Target.Name, m.Target.DataType, notes)
+	}
+	if 0 < len(tt.PrimaryKey) {
+		fmt.Printf("    primary key (%s)\n", strings.Join(tt.PrimaryKey, ","))
+	}
+	for _, index := range tt.Indexes {
+		fmt.Printf("    index %s (%s)\n", index.Name, strings.Join(index.Columns, ","))
+	}
+	fmt.Printf("    %s\n", tt.selectQuery())
+}
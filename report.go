@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ViewCreated      = "created"
+	ViewReplaced     = "replaced"
+	ViewUnchanged    = "unchanged"
+	ViewFailed       = "failed"
+	ViewSkipped      = "skipped"
+	ViewDropped      = "dropped"
+	ViewMaterialized = "materialized"
+
+	ViewReportJSON = "views.json" // file name of the JSON report in the report directory
+	ViewReportText = "views.txt"  // file name of the text summary in the report directory
+)
+
+// ViewResult - outcome of a view on a run
+type ViewResult struct {
+	Database   string   `json:"database"`
+	Name       string   `json:"name"`
+	Target     string   `json:"target,omitempty"` // target view name
+	Status     string   `json:"status"`
+	Source     string   `json:"source,omitempty"`     // T-SQL definition
+	Translated string   `json:"translated,omitempty"` // MySQL definition
+	Error      string   `json:"error,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	Rows       int      `json:"rows,omitempty"`     // rows copied into a materialized view
+	Template   string   `json:"template,omitempty"` // template the view is generated from
+}
+
+// ViewReport - outcomes of the views of a run
+type ViewReport struct {
+	Time  time.Time    `json:"time"`
+	Views []ViewResult `json:"views"`
+}
+
+// Count - views of the status
+func (r ViewReport) Count(status string) int {
+	count := 0
+	for _, v := range r.Views {
+		if v.Status == status {
+			count += 1
+		}
+	}
+	return count
+}
+
+// Summary - counts of the statuses, in a line
+func (r ViewReport) Summary() string {
+	counts := make([]string, 0)
+	for _, status := range []string{ViewCreated, ViewReplaced, ViewMaterialized, ViewUnchanged, ViewFailed, ViewSkipped, ViewDropped} {
+		if count := r.Count(status); 0 < count {
+			counts = append(counts, fmt.Sprintf("%d %s", count, status))
+		}
+	}
+	if len(counts) <= 0 {
+		return "no views"
+	}
+	return strings.Join(counts, ", ")
+}
+
+// Text - readable summary, failed and skipped views with their definitions and errors
+func (r ViewReport) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "views %s: %s\n", r.Time.Format(time.RFC3339), r.Summary())
+
+	views := append([]ViewResult{}, r.Views...)
+	sort.SliceStable(views, func(i, j int) bool {
+		if views[i].Database != views[j].Database {
+			return views[i].Database < views[j].Database
+		}
+		return views[i].Name < views[j].Name
+	})
+	for _, v := range views {
+		fmt.Fprintf(&sb, "\n%s %s.%s", strings.ToUpper(v.Status), v.Database, v.Name)
+		if 0 < len(v.Target) && v.Target != v.Name {
+			fmt.Fprintf(&sb, " -> %s", v.Target)
+		}
+		if v.Status == ViewMaterialized {
+			fmt.Fprintf(&sb, " (%d rows)", v.Rows)
+		}
+		sb.WriteString("\n")
+		for _, w := range v.Warnings {
+			fmt.Fprintf(&sb, "  WARN %s\n", w)
+		}
+		if v.Status != ViewFailed && v.Status != ViewSkipped {
+			continue
+		}
+		if 0 < len(v.Error) {
+			fmt.Fprintf(&sb, "  ERROR %s\n", v.Error)
+		}
+		if 0 < len(v.Source) {
+			fmt.Fprintf(&sb, "  -- T-SQL\n%s\n", indentText(v.Source))
+		}
+		if 0 < len(v.Translated) {
+			fmt.Fprintf(&sb, "  -- MySQL\n%s\n", indentText(v.Translated))
+		}
+	}
+	return sb.String()
+}
+
+// indentText - every line of the text indented
+func indentText(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\r\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "    " + strings.TrimRight(line, "\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Write - write the JSON report and the text summary in the directory
+func (r ViewReport) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, ViewReportJSON), contents, 0666); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, ViewReportText), []byte(r.Text()), 0666)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleViewReport() ViewReport {
+	return ViewReport{
+		Time: time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC),
+		Views: []ViewResult{
+			{Database: "cheil", Name: "v_ok", Status: ViewCreated, Translated: "CREATE VIEW `v_ok` AS SELECT 1"},
+			{Database: "cheil", Name: "v_bad", Status: ViewFailed,
+				Source:     "CREATE VIEW [v_bad] AS\r\nSELECT TOP 10 PERCENT a FROM t",
+				Translated: "CREATE VIEW `v_bad` AS\r\nSELECT TOP 10 PERCENT a FROM t",
+				Error:      "Error 1064: You have an error in your SQL syntax",
+				Warnings:   []string{"selects from tables missing on the target: t"}},
+			{Database: "cheil", Name: "v_same", Status: ViewUnchanged},
+		},
+	}
+}
+
+func TestViewReportText(t *testing.T) {
+	report := sampleViewReport()
+	if s := report.Summary(); s != "1 created, 1 unchanged, 1 failed" {
+		t.Errorf("unexpected summary %s", s)
+	}
+	expect := `views 2021-05-10T12:00:00Z: 1 created, 1 unchanged, 1 failed
+
+FAILED cheil.v_bad
+  WARN selects from tables missing on the target: t
+  ERROR Error 1064: You have an error in your SQL syntax
+  -- T-SQL
+    CREATE VIEW [v_bad] AS
+    SELECT TOP 10 PERCENT a FROM t
+  -- MySQL
+    CREATE VIEW ` + "`v_bad`" + ` AS
+    SELECT TOP 10 PERCENT a FROM t
+
+CREATED cheil.v_ok
+
+UNCHANGED cheil.v_same
+`
+	if text := report.Text(); text != expect {
+		t.Errorf("expected %s but %s", expect, text)
+	}
+	if s := (ViewReport{}).Summary(); s != "no views" {
+		t.Errorf("unexpected empty summary %s", s)
+	}
+}
+
+func TestViewReportWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	report := sampleViewReport()
+	if err = report.Write(filepath.Join(dir, "views")); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "views", ViewReportJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read ViewReport
+	if err = json.Unmarshal(contents, &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Views) != 3 || read.Views[1].Error != report.Views[1].Error || !read.Time.Equal(report.Time) {
+		t.Errorf("unexpected report %v", read)
+	}
+	text, err := ioutil.ReadFile(filepath.Join(dir, "views", ViewReportText))
+	if err != nil || !strings.HasPrefix(string(text), "views 2021-05-10T12:00:00Z") {
+		t.Errorf("unexpected summary %s %v", text, err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const DefaultSchemaPath = "./schema" // directory of the exported DDL files
+
+// RunSchema runs the schema subcommand, export [dir] or diff [--json]
+func RunSchema(args ...string) {
+	if len(args) <= 0 {
+		log.Fatal("Schema command must be in one of (export | diff)")
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "export":
+		dir := DefaultSchemaPath
+		if 1 < len(args) {
+			dir = args[1]
+		}
+		ExportSchema(dir)
+	case "diff":
+		asJSON := 1 < len(args) && args[1] == "--json"
+		out := io.Writer(os.Stdout)
+		if asJSON {
+			var restore func()
+			out, restore = jsonOutput()
+			defer restore()
+		}
+		DiffSchema(out, asJSON)
+	default:
+		log.Fatalf("invalid schema command : %s", args[0])
+	}
+}
+
+// ExportSchema writes the target DDL of the configured tables and views to the directory,
+// a file per table in {database}/tables and per view in {database}/views
+func ExportSchema(dir string) {
+	settings := GetConfigure(ConfigPath)
+
+	schemas := make([]string, 0, len(settings.Targets))
+	for schema := range settings.Targets {
+		schemas = append(schemas, schema)
+	}
+	sort.Strings(schemas)
+
+	for _, schema := range schemas {
+		// open and close source
+		source, _ := OpenConnection(settings.Connectors[KEY_CNX_SOURCE], schema)
+		defer source.Close()
+
+		database := settings.TargetDatabase(schema)
+		fmt.Printf("DB %s -> %s\n", schema, database)
+		// expand table selectors
+		transfers := expandTargets(source, settings.Targets[schema], settings.IndexColumns)
+
+		names := NameMap{}
+		for _, task := range transfers {
+			task = settings.Resolve(schema, task)
+			tt := TransferTask{Source: source, Setting: task}
+			if err := tt.prepareColumns(); err != nil {
+				fmt.Println(err.Error())
+				continue
+			}
+			names.Record(database+"."+task.Target, tt.Columns)
+
+			path := filepath.Join(dir, schemaFileName(database), "tables", schemaFileName(task.Target)+".sql")
+			writeSchemaFile(path, tt.tableDefinition().CreateScript())
+		}
+
+		// selected views on the target column names of the exported tables
+		vt := exportViewTask(settings, source, schema, names)
+		exportViews(dir, &vt, listMSSQLViews(source))
+	}
+}
+
+// exportViewTask - view task of the schema on the target column names of the exported tables
+func exportViewTask(settings *Settings, source *sql.DB, schema string, names NameMap) ViewTask {
+	database := settings.TargetDatabase(schema)
+	return ViewTask{
+		Source:    source,
+		Schema:    schema,
+		Database:  database,
+		Databases: settings.TargetDatabases(),
+		Tables:    settings.TargetTables(),
+		Setting:   settings.Views,
+		Naming:    settings.Naming,
+		Columns:   names.Columns(database),
+	}
+}
+
+// exportViews writes the generated and the selected views to {database}/views,
+// a generated view takes the place of a source view of the same name
+func exportViews(dir string, vt *ViewTask, views map[string]string) {
+	vt.selectViews(views)
+	generated := make(map[string]bool)
+	templated, _ := vt.generateViews()
+	for _, view := range templated {
+		generated[strings.ToLower(view.Name)] = true
+		path := filepath.Join(dir, schemaFileName(vt.Database), "views", schemaFileName(view.Name)+".sql")
+		writeSchemaFile(path, view.Definition+";\n")
+	}
+	for name, def := range views {
+		if !vt.Setting.Selected(name) || generated[strings.ToLower(vt.targetView(name))] {
+			continue
+		}
+		path := filepath.Join(dir, schemaFileName(vt.Database), "views", schemaFileName(vt.targetView(name))+".sql")
+		writeSchemaFile(path, vt.viewQuery(def)+";\n")
+	}
+}
+
+// schemaFileName - file name of the object name, path separators replaced
+func schemaFileName(name string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", ":", "_", "*", "_", "?", "_", `"`, "_", "<", "_", ">", "_", "|", "_").Replace(name)
+}
+
+// writeSchemaFile - write the DDL to the path, creating the directories
+func writeSchemaFile(path string, ddl string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	if err := ioutil.WriteFile(path, []byte(ddl), 0666); err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Printf("  %s\n", path)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteSchemaFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := schemaFileName("Angola_52/jinyeong.kim_cheil.com")
+	if name != "Angola_52_jinyeong.kim_cheil.com" {
+		t.Errorf("unexpected file name %s", name)
+	}
+	path := filepath.Join(dir, "cheil", "views", name+".sql")
+	writeSchemaFile(path, "CREATE VIEW `v` AS SELECT 1;\n")
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "CREATE VIEW `v` AS SELECT 1;\n" {
+		t.Errorf("unexpected contents %s", contents)
+	}
+}
+
+// fakeDriver - database/sql driver answering the queries of a test with fixed rows
+type fakeDriver map[string]fakeRows
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+type fakeConn struct{ queries fakeDriver }
+type fakeTx struct{}
+type fakeStmt struct {
+	queries fakeDriver
+	query   string
+}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{queries: d}, nil }
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{queries: c.queries, query: query}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+func (s fakeStmt) Close() error              { return nil }
+func (s fakeStmt) NumInput() int             { return -1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, exists := s.queries[s.query]; !exists {
+		return nil, fmt.Errorf("unexpected statement %s", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+func (tx fakeTx) Commit() error   { return nil }
+func (tx fakeTx) Rollback() error { return nil }
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, exists := s.queries[s.query]
+	if !exists {
+		return nil, fmt.Errorf("unexpected query %s", s.query)
+	}
+	return &rows, nil
+}
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) <= r.next {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+func TestExportViews(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	parameters := "SELECT DISTINCT Country AS country, Campaign AS campaign FROM Campaigns"
+	sql.Register("fake_export", fakeDriver{parameters: {
+		columns: []string{"Country", "Campaign"},
+		values:  [][]driver.Value{{"Angola", "52"}, {"Kenya", nil}},
+	}})
+	source, err := sql.Open("fake_export", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	settings := &Settings{
+		Schemas: map[string]SchemaSetting{"Cheil": {Database: "cheil"}},
+		Views: ViewSetting{
+			Include: []string{"v_*"},
+			Templates: []ViewTemplate{{
+				Name:       "campaigns",
+				View:       "{country}_{campaign}",
+				Select:     "SELECT * FROM Cleansed_Dataset WHERE Country = {country} AND Campaign = {campaign}",
+				Parameters: parameters,
+			}},
+		},
+	}
+	vt := exportViewTask(settings, source, "Cheil", NameMap{})
+	exportViews(dir, &vt, map[string]string{"v_orders": "CREATE VIEW v_orders AS SELECT 1 AS one"})
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "cheil", "views", "Angola_52.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "CREATE VIEW `Angola_52` AS SELECT * FROM Cleansed_Dataset WHERE Country = 'Angola' AND Campaign = '52';\n"
+	if string(contents) != expect {
+		t.Errorf("expected %s but %s", expect, contents)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cheil", "views", "Kenya_.sql")); !os.IsNotExist(err) {
+		t.Errorf("view generated on a NULL parameter")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cheil", "views", "v_orders.sql")); err != nil {
+		t.Errorf("source view not exported: %s", err)
+	}
+}
@@ -30,6 +30,10 @@ func NewService() Service {
 	log.Print(" >> Service created")
 
 	srv.crontab.AddFunc(settings.Schedule, RunTransferTables)
+	// views, materialized ones refreshed, on their own schedule
+	if 0 < len(settings.Views.Schedule) {
+		srv.crontab.AddFunc(settings.Views.Schedule, RunTransferViews)
+	}
 
 	// for _, t := range Tasks {
 	// 	srv.crontab.AddJob(t.Schedule, t)
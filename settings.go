@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,6 +14,10 @@ const (
 	ConfigPath     = "./cron.yaml"
 	KEY_CNX_SOURCE = "legacy"
 	KEY_CNX_TARGET = "replica"
+
+	DefaultSourceSchema = "dbo"     // source schema (table owner) when not specified
+	DefaultTableName    = "{table}" // target table name template when not specified
+	DefaultViewName     = "{view}"  // target view name template when not specified
 )
 
 /** Configure settings **/
@@ -17,22 +25,171 @@ var ServiceConfig *Settings
 
 // Settings - yaml settings (cron.yaml)
 type Settings struct {
-	Connectors map[string]ConnectionSetting      `yaml:"connectors"` // Connectors determine database connector config
-	Schedule   string                            `yaml:"schedule"`   // Crontab Schedule
-	Successor  string                            `yaml:"successor"`  // (yaml) file that contains per-table latest synced row records
-	Targets    map[string][]TableTransferSetting `yaml:"targets"`    // Schema(key) per transfer setups(per-table)
+	Connectors      map[string]ConnectionSetting      `yaml:"connectors"`       // Connectors determine database connector config
+	Schedule        string                            `yaml:"schedule"`         // Crontab Schedule
+	Successor       string                            `yaml:"successor"`        // (yaml) file that contains per-table latest synced row records
+	Targets         map[string][]TableTransferSetting `yaml:"targets"`          // Schema(key) per transfer setups(per-table)
+	Schemas         map[string]SchemaSetting          `yaml:"schemas"`          // Schema(key) per target mapping
+	TableName       string                            `yaml:"table_name"`       // Target table name template, e.g. `legacy_{schema}_{table}`
+	Salt            string                            `yaml:"salt"`             // default salt of hash_sha256 transforms
+	IndexColumns    []string                          `yaml:"index_columns"`    // index column candidates, the first found is used when a table has no index
+	TypeOverrides   []TypeOverride                    `yaml:"type_overrides"`   // target types of matching columns, on every schema
+	Charset         string                            `yaml:"charset"`          // charset of generated tables, utf8mb4 when empty
+	Collation       string                            `yaml:"collation"`        // collation of generated tables, charset default when empty
+	IndexWatermark  bool                              `yaml:"index_watermark"`  // index the watermark column of every table
+	ComputedColumns string                            `yaml:"computed_columns"` // computed columns as generated(default) or materialize(d plain) columns
+	Conversion      ConversionSetting                 `yaml:"conversion"`       // value conversion between the source and the target
+	Naming          NamingSetting                     `yaml:"naming"`           // normalization of the target column names
+	NameMap         string                            `yaml:"name_map"`         // (yaml) file that records the target column names per table, for views
+	Views           ViewSetting                       `yaml:"views"`            // view replication
+}
+
+// ViewSetting - replication of the source views
+type ViewSetting struct {
+	Fingerprints string `yaml:"fingerprints"` // (yaml) file of the applied view fingerprints, existing views are kept as they are when empty
+	DropRemoved  bool   `yaml:"drop_removed"` // drop the target views created from source views that no longer exist
+	Report       string `yaml:"report"`       // directory of the run reports, views.json and views.txt
+
+	Include []string          `yaml:"include"` // view name patterns to copy, every view when empty
+	Exclude []string          `yaml:"exclude"` // view name patterns not to copy
+	Name    string            `yaml:"name"`    // target view name template, e.g. `{schema}_{view}`
+	Rename  map[string]string `yaml:"rename"`  // source view(key) to target view name, precedes the template
+	Naming  NamingSetting     `yaml:"naming"`  // normalization of {view} in the template, kept when empty
+
+	Schedule string                `yaml:"schedule"` // crontab schedule of the view replication, run by command only when empty
+	Options  map[string]ViewOption `yaml:"options"`  // source view(key) per option
+
+	VerifyRows int `yaml:"verify_rows"` // views of up to the rows are verified row by row, 1000 when empty
+
+	Templates []ViewTemplate `yaml:"templates"` // views generated from templates, in place of the source views of the same names. removed ones are dropped by drop_removed
+}
+
+// ViewOption - replication option of a source view
+type ViewOption struct {
+	Materialize bool                       `yaml:"materialize"` // copy the result of the source view into a table of the target view name
+	Transforms  map[string]ColumnTransform `yaml:"transforms"`  // view column(key) per value transform of the materialized table
+}
+
+// Option - option of the source view, the zero option when not set
+func (v ViewSetting) Option(name string) ViewOption {
+	for from, option := range v.Options {
+		if strings.EqualFold(from, name) {
+			return option
+		}
+	}
+	return ViewOption{}
+}
+
+// Selected - whether the view is copied
+func (v ViewSetting) Selected(name string) bool {
+	if 0 < len(v.Include) && !matchAny(v.Include, name) {
+		return false
+	}
+	return !matchAny(v.Exclude, name)
+}
+
+// TargetView - target view name of the source view.
+// placeholders {schema}, {database} and {view} are replaced in the template
+func (v ViewSetting) TargetView(schema string, database string, name string) string {
+	for from, to := range v.Rename {
+		if strings.EqualFold(from, name) {
+			return to
+		}
+	}
+	template := v.Name
+	if len(template) <= 0 {
+		template = DefaultViewName
+	}
+	view := name
+	if 0 < len(v.Naming.Strategy) {
+		view = v.Naming.Normalize(name)
+	}
+	return truncateName(strings.NewReplacer(
+		"{schema}", schema,
+		"{database}", database,
+		"{view}", view,
+	).Replace(template))
+}
+
+// SchemaSetting - target mapping of a source schema (database)
+type SchemaSetting struct {
+	Database  string `yaml:"database"`   // target database, same as the source schema when empty
+	TableName string `yaml:"table_name"` // overrides Settings.TableName for the schema
+
+	TypeOverrides []TypeOverride `yaml:"type_overrides"` // target types of matching columns, precedes the global overrides
+}
+
+// TypeOverride - target type of the columns matching the source type and column name patterns.
+// an empty pattern matches any
+type TypeOverride struct {
+	SourceType string `yaml:"source_type"` // source type pattern, e.g. varchar(4000), numeric*
+	Column     string `yaml:"column"`      // column name pattern
+	Type       string `yaml:"type"`        // target type
 }
 
 // ConnectionSetting - Database Connector
 type ConnectionSetting struct {
-	Driver string `yaml:"driver"`
-	DSN    string `yaml:"dsn"`
+	Driver   string `yaml:"driver"`
+	DSN      string `yaml:"dsn"`
+	TimeZone string `yaml:"timezone"` // zone of the times on the database, e.g. +09:00, Asia/Seoul
+}
+
+// DataSource - data source name of the database, with the session time zone on mysql
+func (c ConnectionSetting) DataSource(database string) string {
+	dsn := c.DSN + database
+	if c.Driver != "mysql" || len(c.TimeZone) <= 0 {
+		return dsn
+	}
+	zone, err := loadZone(c.TimeZone)
+	if err != nil {
+		fmt.Printf("invalid time zone %s: %s\n", c.TimeZone, err.Error())
+		return dsn
+	}
+	// session time_zone takes the current offset of the zone
+	offset := time.Now().In(zone).Format("-07:00")
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "time_zone=" + url.QueryEscape("'"+offset+"'")
 }
 
 // TableTransferSetting - Target transfer table
 type TableTransferSetting struct {
-	Name  string `yaml:"table"`
-	Index string `yaml:"index"`
+	Name         string          `yaml:"table"`
+	Index        string          `yaml:"index"`
+	SourceSchema string          `yaml:"source_schema"` // source table owner, dbo when empty
+	Target       string          `yaml:"target"`        // target table name, overrides the table name template
+	Columns      ColumnSelection `yaml:"columns"`       // columns to copy
+	Where        string          `yaml:"where"`         // source predicate, appended to the query
+	Lookback     string          `yaml:"lookback"`      // duration to rewind the watermark on each run, e.g. 48h
+
+	Transforms    map[string]ColumnTransform `yaml:"transforms"`     // source column(key) per value transform
+	Types         map[string]string          `yaml:"types"`          // source column(key) per target type, precedes every override
+	TypeOverrides []TypeOverride             `yaml:"type_overrides"` // target types of matching columns, precedes the schema overrides
+
+	Charset   string `yaml:"charset"`   // charset of the generated table, overrides Settings.Charset
+	Collation string `yaml:"collation"` // collation of the generated table, overrides Settings.Collation
+
+	IndexWatermark  bool          `yaml:"index_watermark"`  // index the watermark column, set by Settings.IndexWatermark as well
+	ComputedColumns string        `yaml:"computed_columns"` // overrides Settings.ComputedColumns
+	Naming          NamingSetting `yaml:"naming"`           // overrides Settings.Naming
+}
+
+// ColumnSelection - source columns to copy and their target names.
+// column names are matched case-insensitively, as SQL Server does
+type ColumnSelection struct {
+	Include []string          `yaml:"include"` // columns to copy, every column when empty
+	Exclude []string          `yaml:"exclude"` // columns not to copy
+	Rename  map[string]string `yaml:"rename"`  // source column(key) to target column name
+}
+
+// Owner - source schema (table owner) of the table
+func (t TableTransferSetting) Owner() string {
+	if len(t.SourceSchema) <= 0 {
+		return DefaultSourceSchema
+	}
+	return t.SourceSchema
 }
 
 // LoadFromYaml - load any contents from yaml file.
@@ -68,6 +225,147 @@ func GetConfigure(path string) *Settings {
 	return ServiceConfig
 }
 
+// TargetDatabase - target database name of the source schema
+func (s *Settings) TargetDatabase(schema string) string {
+	if sc, exists := s.Schemas[schema]; exists && 0 < len(sc.Database) {
+		return sc.Database
+	}
+	return schema
+}
+
+// TargetTable - target table name of the source table.
+// placeholders {schema}, {database}, {owner} and {table} are replaced in the template
+func (s *Settings) TargetTable(schema string, t TableTransferSetting) string {
+	if 0 < len(t.Target) {
+		return t.Target
+	}
+	template := s.TableName
+	if sc, exists := s.Schemas[schema]; exists && 0 < len(sc.TableName) {
+		template = sc.TableName
+	}
+	if len(template) <= 0 {
+		template = DefaultTableName
+	}
+	return truncateName(strings.NewReplacer(
+		"{schema}", schema,
+		"{database}", s.TargetDatabase(schema),
+		"{owner}", t.Owner(),
+		"{table}", t.Name,
+	).Replace(template))
+}
+
+// TargetDatabases - target databases of the replicated schemas, by the schema(key) in lower case
+func (s *Settings) TargetDatabases() map[string]string {
+	databases := make(map[string]string, len(s.Targets))
+	for schema := range s.Targets {
+		databases[strings.ToLower(schema)] = s.TargetDatabase(schema)
+	}
+	return databases
+}
+
+// TargetTables - target names of the tables configured by name, by schema.owner.table(key) in lower case.
+// tables selected by patterns are not listed
+func (s *Settings) TargetTables() map[string]string {
+	tables := make(map[string]string)
+	for schema, transfers := range s.Targets {
+		for _, t := range transfers {
+			if !isTablePattern(t.Name) {
+				tables[strings.ToLower(schema+"."+t.Owner()+"."+t.Name)] = s.TargetTable(schema, t)
+			}
+		}
+	}
+	return tables
+}
+
+// resolveTransforms - transforms with the default salt filled
+func (s *Settings) resolveTransforms(transforms map[string]ColumnTransform) map[string]ColumnTransform {
+	if len(transforms) <= 0 {
+		return transforms
+	}
+	resolved := make(map[string]ColumnTransform, len(transforms))
+	for name, ct := range transforms {
+		if ct.Type == TransformHash && len(ct.Salt) <= 0 {
+			ct.Salt = s.Salt
+		}
+		resolved[name] = ct
+	}
+	return resolved
+}
+
+// ResolveViews - view setting with the default salt filled in the transforms of the options
+func (s *Settings) ResolveViews() ViewSetting {
+	v := s.Views
+	if 0 < len(v.Options) {
+		options := make(map[string]ViewOption, len(v.Options))
+		for name, option := range v.Options {
+			option.Transforms = s.resolveTransforms(option.Transforms)
+			options[name] = option
+		}
+		v.Options = options
+	}
+	return v
+}
+
+// TransformedTables - source table names and patterns of the schema with transforms
+func (s *Settings) TransformedTables(schema string) []string {
+	tables := make([]string, 0)
+	for _, t := range s.Targets[schema] {
+		if 0 < len(t.Transforms) {
+			tables = append(tables, t.Name)
+		}
+	}
+	return tables
+}
+
+// Resolve - table setting with the target name and defaults filled
+func (s *Settings) Resolve(schema string, t TableTransferSetting) TableTransferSetting {
+	t.Target = s.TargetTable(schema, t)
+	t.Transforms = s.resolveTransforms(t.Transforms)
+	// table, schema then global type overrides
+	overrides := append([]TypeOverride{}, t.TypeOverrides...)
+	if sc, exists := s.Schemas[schema]; exists {
+		overrides = append(overrides, sc.TypeOverrides...)
+	}
+	t.TypeOverrides = append(overrides, s.TypeOverrides...)
+	// charset and collation, the global collation only goes with the global charset
+	if len(t.Charset) <= 0 {
+		t.Charset = s.Charset
+		if len(t.Collation) <= 0 {
+			t.Collation = s.Collation
+		}
+	}
+	if len(t.Charset) <= 0 {
+		t.Charset = DefaultCharset
+	}
+	t.IndexWatermark = t.IndexWatermark || s.IndexWatermark
+	if len(t.ComputedColumns) <= 0 {
+		t.ComputedColumns = s.ComputedColumns
+	}
+	if len(t.Naming.Strategy) <= 0 {
+		t.Naming = s.Naming
+	}
+	return t
+}
+
+// TimeZones - zones of the legacy and the replica times.
+// replica times are in the replica connector zone, or the conversion zone when it has none
+func (s *Settings) TimeZones() TimeZones {
+	zones := TimeZones{Target: s.Conversion.Location()}
+	if source := s.Connectors[KEY_CNX_SOURCE]; 0 < len(source.TimeZone) {
+		if zone, err := loadZone(source.TimeZone); err == nil {
+			zones.Source = zone
+		} else {
+			fmt.Printf("invalid time zone %s: %s\n", source.TimeZone, err.Error())
+		}
+	}
+	if target := s.Connectors[KEY_CNX_TARGET]; 0 < len(target.TimeZone) {
+		if zone, err := loadZone(target.TimeZone); err == nil {
+			zones.Target = zone
+		}
+	}
+	return zones
+}
+
 /** Successor read/write **/
 var SuccessConfig SuccessorSetting
 
@@ -82,3 +380,37 @@ func GetSuccessor(path string) SuccessorSetting {
 	}
 	return SuccessConfig
 }
+
+// successKey - successor key of the table, owner.table
+func successKey(t TableTransferSetting) string {
+	return t.Owner() + "." + t.Name
+}
+
+// Watermark - latest success of the table on the schema, empty when not recorded.
+// a success keyed by the table name alone, recorded before the owners, moves to the owner key
+// of the first table asking for it
+func (ss SuccessorSetting) Watermark(schema string, t TableTransferSetting) interface{} {
+	if _, exists := ss[schema]; !exists {
+		ss[schema] = make(map[string]interface{}, 0)
+	}
+	records := ss[schema]
+	key := successKey(t)
+	if _, exists := records[key]; !exists {
+		if legacy, found := records[t.Name]; found {
+			records[key] = legacy
+			delete(records, t.Name)
+		}
+	}
+	if sc := records[key]; sc != nil {
+		return sc
+	}
+	return ""
+}
+
+// Record - record the latest success of the table on the schema
+func (ss SuccessorSetting) Record(schema string, t TableTransferSetting, sc interface{}) {
+	if _, exists := ss[schema]; !exists {
+		ss[schema] = make(map[string]interface{}, 0)
+	}
+	ss[schema][successKey(t)] = sc
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -88,3 +89,180 @@ func TestConfigure(t *testing.T) {
 		t.Error("no target")
 	}
 }
+
+func TestTargetMapping(t *testing.T) {
+	conf := &Settings{
+		TableName: "legacy_{schema}_{table}",
+		Schemas: map[string]SchemaSetting{
+			"CheilOptimizer_DM": {Database: "staging_dm"},
+			"Other_DM":          {TableName: "{owner}_{table}"},
+		},
+	}
+
+	if db := conf.TargetDatabase("CheilOptimizer_DM"); db != "staging_dm" {
+		t.Errorf("mapped database expected staging_dm but %s", db)
+	}
+	if db := conf.TargetDatabase("Unknown_DM"); db != "Unknown_DM" {
+		t.Errorf("unmapped database expected Unknown_DM but %s", db)
+	}
+
+	samples := []struct {
+		Schema string
+		Table  TableTransferSetting
+		Expect string
+	}{
+		{"CheilOptimizer_DM", TableTransferSetting{Name: "Cleansed_Dataset"}, "legacy_CheilOptimizer_DM_Cleansed_Dataset"},
+		{"Other_DM", TableTransferSetting{Name: "orders", SourceSchema: "sales"}, "sales_orders"},
+		{"Other_DM", TableTransferSetting{Name: "orders"}, "dbo_orders"},
+		{"Other_DM", TableTransferSetting{Name: "orders", Target: "fixed"}, "fixed"},
+		{"Other_DM", TableTransferSetting{Name: strings.Repeat("x", 70)}, "dbo_" + strings.Repeat("x", 60)},
+	}
+	for i, s := range samples {
+		if name := conf.TargetTable(s.Schema, s.Table); name != s.Expect {
+			t.Errorf("[%d] expected %s but %s", i, s.Expect, name)
+		}
+	}
+}
+
+func TestResolveTypeOverrides(t *testing.T) {
+	conf := &Settings{
+		TypeOverrides: []TypeOverride{{SourceType: "numeric*", Type: "global"}},
+		Schemas: map[string]SchemaSetting{
+			"CheilOptimizer_DM": {TypeOverrides: []TypeOverride{{SourceType: "numeric*", Type: "schema"}}},
+		},
+	}
+	table := TableTransferSetting{Name: "Cleansed_Dataset", TypeOverrides: []TypeOverride{{SourceType: "numeric*", Type: "table"}}}
+
+	expects := []string{"table", "schema", "global"}
+	resolved := conf.Resolve("CheilOptimizer_DM", table)
+	if len(resolved.TypeOverrides) != len(expects) {
+		t.Fatalf("expected %d overrides but %v", len(expects), resolved.TypeOverrides)
+	}
+	for i, o := range resolved.TypeOverrides {
+		if o.Type != expects[i] {
+			t.Errorf("[%d] expected %s but %s", i, expects[i], o.Type)
+		}
+	}
+	if len(table.TypeOverrides) != 1 {
+		t.Error("table setting changed on resolve")
+	}
+}
+
+func TestResolveCharset(t *testing.T) {
+	conf := &Settings{Collation: "utf8mb4_unicode_ci"}
+	if r := conf.Resolve("DM", TableTransferSetting{Name: "a"}); r.Charset != DefaultCharset || r.Collation != "utf8mb4_unicode_ci" {
+		t.Errorf("default charset expected but %s %s", r.Charset, r.Collation)
+	}
+	if r := conf.Resolve("DM", TableTransferSetting{Name: "a", Charset: "latin1"}); r.Charset != "latin1" || r.Collation != "" {
+		t.Errorf("table charset expected without the global collation but %s %s", r.Charset, r.Collation)
+	}
+	if r := conf.Resolve("DM", TableTransferSetting{Name: "a", Collation: "utf8mb4_bin"}); r.Collation != "utf8mb4_bin" {
+		t.Errorf("table collation expected but %s", r.Collation)
+	}
+}
+
+func TestDataSource(t *testing.T) {
+	samples := []struct {
+		Conn   ConnectionSetting
+		Expect string
+	}{
+		{ConnectionSetting{Driver: "mysql", DSN: "user:pw@tcp(db:3306)/"}, "user:pw@tcp(db:3306)/sales"},
+		{ConnectionSetting{Driver: "mysql", DSN: "user:pw@tcp(db:3306)/", TimeZone: "+09:00"}, "user:pw@tcp(db:3306)/sales?time_zone=%27%2B09%3A00%27"},
+		{ConnectionSetting{Driver: "mysql", DSN: "user:pw@tcp(db:3306)/", TimeZone: "UTC"}, "user:pw@tcp(db:3306)/sales?time_zone=%27%2B00%3A00%27"},
+		{ConnectionSetting{Driver: "sqlserver", DSN: "sqlserver://db?database=", TimeZone: "+09:00"}, "sqlserver://db?database=sales"},
+	}
+	for i, s := range samples {
+		if dsn := s.Conn.DataSource("sales"); dsn != s.Expect {
+			t.Errorf("[%d] expected %s but %s", i, s.Expect, dsn)
+		}
+	}
+	conn := ConnectionSetting{Driver: "mysql", DSN: "user:pw@tcp(db:3306)/sales?parseTime=true", TimeZone: "-05:00"}
+	if dsn := conn.DataSource(""); dsn != "user:pw@tcp(db:3306)/sales?parseTime=true&time_zone=%27-05%3A00%27" {
+		t.Errorf("unexpected %s", dsn)
+	}
+}
+
+func TestResolveTimeZones(t *testing.T) {
+	s := &Settings{Connectors: map[string]ConnectionSetting{
+		KEY_CNX_SOURCE: {Driver: "sqlserver", TimeZone: "+09:00"},
+		KEY_CNX_TARGET: {Driver: "mysql"},
+	}}
+	zones := s.TimeZones()
+	if _, offset := time.Now().In(zones.Source).Zone(); offset != 9*60*60 {
+		t.Errorf("source zone expected +09:00 but %s", zones.Source)
+	}
+	if zones.Target != time.UTC {
+		t.Errorf("target zone expected UTC but %s", zones.Target)
+	}
+	s.Connectors[KEY_CNX_TARGET] = ConnectionSetting{Driver: "mysql", TimeZone: "-05:00"}
+	if _, offset := time.Now().In(s.TimeZones().Target).Zone(); offset != -5*60*60 {
+		t.Errorf("target zone expected -05:00")
+	}
+	if zones := (&Settings{}).TimeZones(); zones.Source != nil {
+		t.Errorf("source zone expected none but %s", zones.Source)
+	}
+}
+
+func TestViewSettingTarget(t *testing.T) {
+	v := ViewSetting{
+		Include: []string{"v_*", "report_*"},
+		Exclude: []string{"*_tmp"},
+		Name:    "{schema}_{view}",
+		Rename:  map[string]string{"V_Legacy": "legacy"},
+		Naming:  NamingSetting{Strategy: NamingSnakeCase},
+	}
+	selected := map[string]bool{"v_sales": true, "report_daily": true, "v_sales_tmp": false, "orders": false}
+	for name, expect := range selected {
+		if v.Selected(name) != expect {
+			t.Errorf("%s expected selected %v", name, expect)
+		}
+	}
+	if !(ViewSetting{Exclude: []string{"x"}}).Selected("v_sales") {
+		t.Errorf("every view expected selected without include patterns")
+	}
+	targets := map[string]string{"v_sales": "Sales_v_sales", "v_legacy": "legacy", "V Daily Report": "Sales_v_daily_report"}
+	for name, expect := range targets {
+		if got := v.TargetView("Sales", "sales", name); got != expect {
+			t.Errorf("%s expected %s but %s", name, expect, got)
+		}
+	}
+	if got := (ViewSetting{}).TargetView("Sales", "sales", "V Sales"); got != "V Sales" {
+		t.Errorf("expected kept but %s", got)
+	}
+
+	v.Options = map[string]ViewOption{"V_Heavy": {Materialize: true, Transforms: map[string]ColumnTransform{"email": {Type: TransformHash}}}}
+	if !v.Option("v_heavy").Materialize || v.Option("v_sales").Materialize {
+		t.Errorf("only v_heavy expected materialized")
+	}
+	s := &Settings{Salt: "salt", Views: v}
+	if salt := s.ResolveViews().Option("v_heavy").Transforms["email"].Salt; salt != "salt" {
+		t.Errorf("default salt expected but %s", salt)
+	}
+	if 0 < len(v.Options["V_Heavy"].Transforms["email"].Salt) {
+		t.Errorf("settings changed by resolve")
+	}
+}
+
+func TestSuccessorWatermark(t *testing.T) {
+	ss := SuccessorSetting{"sales": {"orders": "2021-05-10"}}
+	dbo := TableTransferSetting{Name: "orders"}
+	owned := TableTransferSetting{Name: "orders", SourceSchema: "sales"}
+
+	// the record without owner moves to the first asking
+	if sc := ss.Watermark("sales", dbo); sc != "2021-05-10" {
+		t.Errorf("legacy watermark expected but %v", sc)
+	}
+	if _, exists := ss["sales"]["orders"]; exists {
+		t.Errorf("legacy key expected moved")
+	}
+	if sc := ss.Watermark("sales", owned); sc != "" {
+		t.Errorf("sales.orders expected apart from dbo.orders but %v", sc)
+	}
+	ss.Record("sales", owned, int64(42))
+	if ss["sales"]["dbo.orders"] != "2021-05-10" || ss["sales"]["sales.orders"] != int64(42) {
+		t.Errorf("unexpected %v", ss["sales"])
+	}
+	if sc := ss.Watermark("other", dbo); sc != "" {
+		t.Errorf("unexpected %v", sc)
+	}
+}
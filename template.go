@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ViewTemplate - views generated from a MySQL SELECT, a view per row of the parameter query.
+// {column} placeholders take the values of the parameter row
+type ViewTemplate struct {
+	Name       string `yaml:"name"`       // template name on the report
+	Schema     string `yaml:"schema"`     // source schema the parameter query runs on, every schema when empty
+	View       string `yaml:"view"`       // view name template, e.g. `{country}_{campaign}_{user}`
+	Select     string `yaml:"select"`     // MySQL SELECT template, the values are placed as string literals
+	Parameters string `yaml:"parameters"` // source query of the view parameters, columns named as the placeholders
+}
+
+// GeneratedView - view of a template on a parameter row
+type GeneratedView struct {
+	Template   string
+	Name       string
+	Definition string // CREATE VIEW statement
+}
+
+var placeholderPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// renderTemplate - template with the placeholders replaced by the quoted values,
+// placeholders without a parameter are kept
+func renderTemplate(template string, params map[string]interface{}, quote func(interface{}) string) string {
+	return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if value, exists := params[strings.ToLower(placeholder[1:len(placeholder)-1])]; exists {
+			return quote(value)
+		}
+		return placeholder
+	})
+}
+
+// templateName - parameter value in a view name, empty on NULL
+func templateName(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return transformString(v)
+}
+
+// templateLiteral - parameter value in a SELECT, a string literal.
+// NULL parameters are rejected by Generate, `col = NULL` would match no row
+func templateLiteral(v interface{}) string {
+	return quoteMySQLString(transformString(v))
+}
+
+// nullParameters - placeholders of the template on a NULL parameter
+func nullParameters(template string, params map[string]interface{}) []string {
+	nulls := make([]string, 0)
+	for _, m := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if value, exists := params[strings.ToLower(m[1])]; exists && value == nil {
+			nulls = append(nulls, m[1])
+		}
+	}
+	return nulls
+}
+
+// Generate - views of the parameter rows, by the rendered name.
+// rows rendering a name already generated or a NULL in the SELECT are left out with a warning
+func (t ViewTemplate) Generate(rows []map[string]interface{}) ([]GeneratedView, []string) {
+	views := make([]GeneratedView, 0, len(rows))
+	warnings := make([]string, 0)
+	names := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		name := truncateName(renderTemplate(t.View, row, templateName))
+		if len(name) <= 0 || placeholderPattern.MatchString(name) {
+			warnings = append(warnings, fmt.Sprintf("template %s: no view name of %v", t.Name, row))
+			continue
+		}
+		if names[strings.ToLower(name)] {
+			warnings = append(warnings, fmt.Sprintf("template %s: view %s generated twice", t.Name, name))
+			continue
+		}
+		if nulls := nullParameters(t.Select, row); 0 < len(nulls) {
+			warnings = append(warnings, fmt.Sprintf("template %s: view %s on NULL %s", t.Name, name, strings.Join(nulls, ", ")))
+			continue
+		}
+		names[strings.ToLower(name)] = true
+		views = append(views, GeneratedView{
+			Template:   t.Name,
+			Name:       name,
+			Definition: fmt.Sprintf("CREATE VIEW %s AS %s", quoteMySQL(name), strings.TrimSpace(renderTemplate(t.Select, row, templateLiteral))),
+		})
+	}
+	return views, warnings
+}
+
+// readTemplateParameters - rows of the parameter query, by the column(key) in lower case
+func readTemplateParameters(source *sql.DB, query string) ([]map[string]interface{}, error) {
+	rs, err := source.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	columns, _ := rs.Columns()
+	rows := make([]map[string]interface{}, 0)
+	for rs.Next() {
+		values := scanRow(rs, columns)
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			row[strings.ToLower(col)] = values[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, rs.Err()
+}
+
+// namePattern - pattern of the view names the template renders
+func (t ViewTemplate) namePattern() *regexp.Regexp {
+	parts := placeholderPattern.Split(t.View, -1)
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("(?i)^" + strings.Join(parts, ".*") + "$")
+}
+
+// generateViews - views of the templates on the schema, with the templates of the parameters not read.
+// rows left out are reported on the template
+func (vt *ViewTask) generateViews() ([]GeneratedView, []ViewTemplate) {
+	views := make([]GeneratedView, 0)
+	failed := make([]ViewTemplate, 0)
+	for _, t := range vt.Setting.Templates {
+		if 0 < len(t.Schema) && !strings.EqualFold(t.Schema, vt.Schema) {
+			continue
+		}
+		rows, err := readTemplateParameters(vt.Source, t.Parameters)
+		if err != nil {
+			vt.report(ViewResult{Name: t.Name, Template: t.Name, Status: ViewFailed, Source: t.Parameters, Error: err.Error()})
+			failed = append(failed, t)
+			continue
+		}
+		generated, warnings := t.Generate(rows)
+		if 0 < len(warnings) {
+			vt.report(ViewResult{Name: t.Name, Template: t.Name, Status: ViewSkipped, Source: t.Parameters,
+				Error: fmt.Sprintf("%d of %d parameter rows left out", len(warnings), len(rows)), Warnings: warnings})
+		}
+		views = append(views, generated...)
+	}
+	return views, failed
+}
+
+// keepTemplateViews - the applied views with the fingerprinted views of the failed templates,
+// not known to be removed while the parameters are not read
+func (vt *ViewTask) keepTemplateViews(applied map[string]string, failed []ViewTemplate) map[string]string {
+	for _, t := range failed {
+		pattern := t.namePattern()
+		for vname := range vt.Fingerprints {
+			if _, exists := applied[vname]; !exists && pattern.MatchString(vname) {
+				applied[vname] = t.Name
+			}
+		}
+	}
+	return applied
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	params := map[string]interface{}{"country": "AO", "campaign": []byte("52"), "user": "o'neil", "cycle": nil}
+	if got := renderTemplate("{Country}_{campaign}_{user}{cycle}", params, templateName); got != "AO_52_o'neil" {
+		t.Errorf("unexpected name %s", got)
+	}
+	sql := "SELECT * FROM Cleansed_Dataset WHERE Country = {country} AND Confirm_user = {user} AND x = {other}"
+	expect := "SELECT * FROM Cleansed_Dataset WHERE Country = 'AO' AND Confirm_user = 'o''neil' AND x = {other}"
+	if got := renderTemplate(sql, params, templateLiteral); got != expect {
+		t.Errorf("expected %s but %s", expect, got)
+	}
+	if nulls := nullParameters(sql+" AND Cycle = {Cycle}", params); len(nulls) != 1 || nulls[0] != "Cycle" {
+		t.Errorf("unexpected NULL parameters %v", nulls)
+	}
+}
+
+func TestGenerateViews(t *testing.T) {
+	vt := ViewTemplate{
+		Name:   "campaigns",
+		View:   "{country}_{campaign}_{user}",
+		Select: "SELECT * FROM Cleansed_Dataset WHERE Campaign_name = {campaign} AND Country = {country}\n",
+	}
+	rows := []map[string]interface{}{
+		{"country": "Angola", "campaign": "52", "user": "user01_example.com"},
+		{"country": "angola", "campaign": "52", "user": "USER01_example.com"},
+		{"country": "Kenya", "campaign": "7"},
+		{"country": "Kenya", "campaign": nil, "user": "user02_example.com"},
+	}
+	views, warnings := vt.Generate(rows)
+	if len(views) != 1 || len(warnings) != 3 {
+		t.Fatalf("expected a view and 3 warnings but %v %v", views, warnings)
+	}
+	expect := "CREATE VIEW `Angola_52_user01_example.com` AS SELECT * FROM Cleansed_Dataset WHERE Campaign_name = '52' AND Country = 'Angola'"
+	if views[0].Name != "Angola_52_user01_example.com" || views[0].Definition != expect || views[0].Template != "campaigns" {
+		t.Errorf("unexpected %v", views[0])
+	}
+}
+
+func TestGenerateViewsReport(t *testing.T) {
+	parameters := "SELECT country, campaign FROM Campaigns"
+	sql.Register("fake_templates", fakeDriver{parameters: {
+		columns: []string{"country", "campaign"},
+		values:  [][]driver.Value{{"Angola", "52"}, {"Kenya", nil}},
+	}})
+	source, _ := sql.Open("fake_templates", "")
+	defer source.Close()
+
+	failing := ViewTemplate{Name: "users", View: "u_{user}", Select: "SELECT {user}", Parameters: "SELECT user FROM Users"}
+	vt := ViewTask{
+		Source: source,
+		Setting: ViewSetting{Templates: []ViewTemplate{
+			{Name: "campaigns", View: "{country}_{campaign}", Select: "SELECT {country}, {campaign}", Parameters: parameters},
+			failing,
+		}},
+		Fingerprints: map[string]string{"u_user01": "f", "Angola_52": "f", "v_removed": "f"},
+	}
+	views, failed := vt.generateViews()
+	if len(views) != 1 || len(failed) != 1 || failed[0].Name != "users" {
+		t.Fatalf("unexpected %v %v", views, failed)
+	}
+	if len(vt.Results) != 2 || vt.Results[0].Status != ViewSkipped || len(vt.Results[0].Warnings) != 1 || vt.Results[1].Status != ViewFailed {
+		t.Errorf("template warnings and failures expected on the report but %v", vt.Results)
+	}
+
+	// views of the failed template are kept from the removal
+	applied := vt.keepTemplateViews(map[string]string{"Angola_52": "campaigns"}, failed)
+	removed := removedViews(applied, map[string]string{"u_user01": "", "Angola_52": "", "v_removed": ""}, vt.Fingerprints)
+	if strings.Join(removed, ",") != "v_removed" {
+		t.Errorf("expected v_removed removed only but %v", removed)
+	}
+}
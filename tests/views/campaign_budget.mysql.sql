@@ -0,0 +1,6 @@
+CREATE VIEW `Campaign_Budget` AS
+-- planned budget of the campaigns, with the country names of the master database
+SELECT s.Campaign_name, m.Country_name, b.Budget_usd, s.cost_usd,
+       s.cost_usd * 100.0 / NULLIF(b.Budget_usd, 0) AS spent_rate
+FROM   `dm_campaign_summary` s, `master_replica`.`master_country` m, `Finance`.`Budget` b
+WHERE  m.Country_code = s.Country AND b.Campaign_name = s.Campaign_name
@@ -0,0 +1,7 @@
+CREATE VIEW `Angola_52_user01_example.com` AS
+SELECT  Date, `Campaign name`, `Destination URL`, `Segment name`, `Creative name`, `Publisher platform`, `Planned cost_usd`, `Planned cost_local`, `Actual cost_usd`, `Actual cost_local`, `Planned Impressions`, 
+               Impressions, `Viewable impression`, `Planned CPM_usd`, `Actual CPM_usd`, `Planned Clicks`, Clicks, `Planned CTR`, CTR, `Planned Views_video`, Views_video, `Video watches at 25%`, `Video watches at 50%`, 
+               `Video watches at 75%`, `Video watches at 100%`, `Link clicks`, CID, `CID 1`, `CID 2`, `Open`, `Open rate`, Campaign_name, Country, Campaign_start_dt, Campaign_end_dt, Cycle, 
+               Currency, Product, Advertiser, `Week in campaign`, Media_type, Media_name, Confirm_user, confirm_date, reg_date, `Tracking code`, Revenue_usd, Revenue_local, ROAS_usd, ROAS_local
+FROM     `dm`.`legacy_cleansed_dataset`
+WHERE  (Campaign_name = '52' AND Country = 'AO' AND `DATE` IS NOT NULL)
@@ -0,0 +1,10 @@
+CREATE VIEW Campaign_Period AS
+-- campaigns running within the last 30 days
+SELECT Campaign_name, Country,
+       LEFT(DATE_FORMAT(Campaign_start_dt, '%Y-%m-%d %H:%i:%s'), 10) AS start_day,
+       STR_TO_DATE(LEFT(DATE_FORMAT(Campaign_end_dt, '%Y%m%d'), 8), '%Y%m%d') AS end_day,
+       DATEDIFF(Campaign_end_dt, Campaign_start_dt) + 1 AS days,
+       ((YEAR(NOW()) - YEAR(Campaign_start_dt)) * 12 + MONTH(NOW()) - MONTH(Campaign_start_dt)) AS months_since_start,
+       TIMESTAMPADD(WEEK, 1, Campaign_end_dt) AS report_due
+FROM   `legacy_cleansed_dataset`
+WHERE  Campaign_end_dt >= TIMESTAMPADD(DAY, -30, NOW())
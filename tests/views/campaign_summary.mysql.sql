@@ -0,0 +1,9 @@
+CREATE VIEW `dm_campaign_summary`
+AS
+SELECT Campaign_name, Country, IFNULL(Media_type, 'Unknown') AS Media_type,
+       SUM(IFNULL(`Actual cost_usd`, 0)) AS cost_usd, SUM(Impressions) AS impressions,
+       IF(SUM(Impressions) > 0, SUM(Clicks) * 100.0 / SUM(Impressions), NULL) AS ctr
+FROM   `legacy_cleansed_dataset`
+WHERE  CHAR_LENGTH(RTRIM(Campaign_name)) > 0
+GROUP BY Campaign_name, Country, IFNULL(Media_type, 'Unknown')
+ORDER BY Campaign_name
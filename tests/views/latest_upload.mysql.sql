@@ -0,0 +1,11 @@
+CREATE VIEW `Latest_Upload` AS
+/* the last upload of every campaign,
+   with the uploader label */
+SELECT c.Campaign_name, c.Country, u.reg_date,
+       CONCAT(c.Country, '_', c.Campaign_name, '_', CAST(u.upload_seq AS CHAR(10))) AS label,
+       CAST(u.`Actual cost_local` AS DECIMAL(18,2)) AS cost_local
+FROM   `dm_campaign_summary` c
+JOIN   (SELECT Campaign_name, reg_date, upload_seq, `Actual cost_local`
+        FROM `legacy_cleansed_dataset`
+        ORDER BY reg_date DESC LIMIT 1) u ON u.Campaign_name = c.Campaign_name
+WHERE  c.Country <> 'KR'
@@ -3,8 +3,8 @@ package main
 import (
 	"database/sql"
 	"fmt"
-	"regexp"
 	"strings"
+	"time"
 
 	_ "github.com/denisenkom/go-mssqldb"
 	_ "github.com/go-sql-driver/mysql"
@@ -12,7 +12,7 @@ import (
 
 // Open database connection
 func OpenConnection(conf ConnectionSetting, database string) (*sql.DB, error) {
-	return sql.Open(conf.Driver, conf.DSN+database)
+	return sql.Open(conf.Driver, conf.DataSource(database))
 }
 
 type TransferTask struct {
@@ -20,12 +20,79 @@ type TransferTask struct {
 	Target  *sql.DB              // target database connector
 	Setting TableTransferSetting // Transfer Settings (with success)
 	Success interface{}          // Concurrent success loaded
+	Columns []ColumnMapping      // Columns to copy, read on demand
+
+	PrimaryKey  []string          // target primary key columns
+	Indexes     []IndexDefinition // target indexes
+	Zones       TimeZones         // zones of the time values
+	IndexColumn ColumnDefinition  // source definition of the index column
 }
 
 type ColumnDefinition struct {
-	Name     string
-	DataType string
-	Nullable bool
+	Name       string
+	DataType   string
+	Nullable   bool
+	SourceType string // type declared on the source, empty for target columns
+	Identity   bool   // identity column, AUTO_INCREMENT on the target
+	Default    string // default expression, T-SQL on the source and MySQL on the target
+	Computed   bool   // computed column, generated on the target
+	Expression string // computed column expression
+	Persisted  bool   // computed column is stored
+}
+
+// ColumnMapping - source column and the target column it lands on
+type ColumnMapping struct {
+	Source     ColumnDefinition
+	Target     ColumnDefinition
+	Transform  *ColumnTransform // value transform, nil to copy as is
+	Overridden bool             // target type set by a type override
+}
+
+// containsName - case-insensitive name lookup
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// targetColumnName - target name of the source column
+func targetColumnName(name string, rename map[string]string) string {
+	for from, to := range rename {
+		if strings.EqualFold(from, name) {
+			return to
+		}
+	}
+	return name
+}
+
+// mapColumns - select source columns to copy and name them on the target
+func mapColumns(columns []ColumnDefinition, selection ColumnSelection) []ColumnMapping {
+	mappings := make([]ColumnMapping, 0, len(columns))
+	for _, col := range columns {
+		if 0 < len(selection.Include) && !containsName(selection.Include, col.Name) {
+			continue
+		}
+		if containsName(selection.Exclude, col.Name) {
+			continue
+		}
+		target := col
+		target.Name = targetColumnName(col.Name, selection.Rename)
+		mappings = append(mappings, ColumnMapping{Source: col, Target: target})
+	}
+	return mappings
+}
+
+// quoteMSSQL - quote the identifier for SQL Server, [name]
+func quoteMSSQL(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// quoteMySQL - quote the identifier for MySQL, `name`
+func quoteMySQL(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
 }
 
 // RunTransferTable
@@ -35,36 +102,48 @@ func RunTransferTables() {
 	// save success at last
 	// defer SaveToYaml(settings.Successor, success)
 
+	zones := settings.TimeZones()
+	names := GetNameMap(settings.NameMap)
+
 	// run each schema
 	for schema, transfers := range settings.Targets {
 		// open and close source
 		source, _ := OpenConnection(settings.Connectors[KEY_CNX_SOURCE], schema)
 		defer source.Close()
 		// open and close target
-		target, _ := OpenConnection(settings.Connectors[KEY_CNX_TARGET], schema)
+		target, _ := OpenConnection(settings.Connectors[KEY_CNX_TARGET], settings.TargetDatabase(schema))
 		defer target.Close()
 
-		if _, exists := success[schema]; !exists {
-			success[schema] = make(map[string]interface{}, 0)
-		}
-		schemaSuccess := success[schema]
-
-		fmt.Printf("DB %s\n", schema)
+		fmt.Printf("DB %s -> %s\n", schema, settings.TargetDatabase(schema))
+		// expand table selectors
+		transfers = expandTargets(source, transfers, settings.IndexColumns)
 
 		for _, task := range transfers {
-			// build transfer task
-			sc, se := schemaSuccess[task.Name]
-			if !se || sc == nil {
-				sc = ""
+			// build transfer task, on the success of owner.table
+			sc := success.Watermark(schema, task)
+			// resolve the target table name
+			task = settings.Resolve(schema, task)
+			fmt.Printf("  TABLE %s.%s -> %s(%s)\n", task.Owner(), task.Name, task.Target, sc)
+			tt := TransferTask{Source: source, Target: target, Setting: task, Success: sc, Zones: zones}
+			if err := tt.prepareColumns(); err != nil {
+				fmt.Println(err.Error())
+				continue
+			}
+			// record the target names for views
+			if 0 < len(settings.NameMap) {
+				names.Record(settings.TargetDatabase(schema)+"."+task.Target, tt.Columns)
+				SaveToYaml(settings.NameMap, names)
+			}
+			// create the table if not exists, no rows are copied without it
+			if err := tt.duplicateTable(); err != nil {
+				continue
 			}
-			fmt.Printf("  TABLE %s(%s)\n", task.Name, sc)
-			tt := TransferTask{source, target, task, sc}
-			// create the table if not exists
-			tt.duplicateTable()
-			// copy rows
-			tt.copyRows()
-
-			success[schema][task.Name] = tt.Success
+			// copy rows, the watermark is kept when rows are not copied
+			if _, err := tt.copyRows(); err != nil {
+				continue
+			}
+
+			success.Record(schema, task, tt.Success)
 			// save on every update
 			SaveToYaml(settings.Successor, success)
 		}
@@ -74,24 +153,54 @@ func RunTransferTables() {
 // RunTransferViews to duplicate views
 func RunTransferViews() {
 	settings := GetConfigure(ConfigPath)
+	fingerprints := GetViewFingerprints(settings.Views.Fingerprints)
+	report := ViewReport{Time: time.Now(), Views: make([]ViewResult, 0)}
 	for schema, _ := range settings.Targets {
 		// open and close source
 		source, _ := OpenConnection(settings.Connectors[KEY_CNX_SOURCE], schema)
 		defer source.Close()
 		// open and close target
-		target, _ := OpenConnection(settings.Connectors[KEY_CNX_TARGET], schema)
+		database := settings.TargetDatabase(schema)
+		target, _ := OpenConnection(settings.Connectors[KEY_CNX_TARGET], database)
 		defer target.Close()
 
-		// duplicate views
-		duplicateView(source, target, schema)
+		// duplicate views, on the recorded target column names
+		vt := ViewTask{
+			Source:       source,
+			Target:       target,
+			Schema:       schema,
+			Database:     database,
+			Databases:    settings.TargetDatabases(),
+			Tables:       settings.TargetTables(),
+			Setting:      settings.ResolveViews(),
+			Table:        settings.Resolve(schema, TableTransferSetting{}),
+			Zones:        settings.TimeZones(),
+			Transformed:  settings.TransformedTables(schema),
+			Naming:       settings.Naming,
+			Columns:      GetNameMap(settings.NameMap).Columns(database),
+			Fingerprints: fingerprints.Database(database),
+		}
+		vt.duplicateViews()
+		report.Views = append(report.Views, vt.Results...)
+		if 0 < len(settings.Views.Fingerprints) {
+			SaveToYaml(settings.Views.Fingerprints, fingerprints)
+		}
+	}
 
+	fmt.Printf("views: %s\n", report.Summary())
+	if 0 < len(settings.Views.Report) {
+		if err := report.Write(settings.Views.Report); err != nil {
+			fmt.Println(err.Error())
+		}
 	}
 }
 
 // SyncTable duplicates table
 func (tt *TransferTask) Sync() {
 	// check target table exists
-	tt.duplicateTable()
+	if err := tt.duplicateTable(); err != nil {
+		return
+	}
 
 	// retrieve success lines
 	tt.copyRows()
@@ -125,8 +234,8 @@ func queryFetchAll(db *sql.DB, query string, args ...interface{}) ([][]interface
 	return rss, nil
 }
 
-func readTableColumns(db *sql.DB, table string, prefix string, build func([]interface{}) ColumnDefinition) []ColumnDefinition {
-	cols, err := queryFetchAll(db, prefix+table)
+func readTableColumns(db *sql.DB, query string, build func([]interface{}) ColumnDefinition, args ...interface{}) []ColumnDefinition {
+	cols, err := queryFetchAll(db, query, args...)
 	columns := make([]ColumnDefinition, len(cols))
 	if err != nil {
 		return nil
@@ -138,51 +247,72 @@ func readTableColumns(db *sql.DB, table string, prefix string, build func([]inte
 	return columns
 }
 
+// columnInt - integer value of the catalog row, 0 on NULL
+func columnInt(v interface{}) int64 {
+	switch value := v.(type) {
+	case int64:
+		return value
+	case int32:
+		return int64(value)
+	case int16:
+		return int64(value)
+	case uint8:
+		return int64(value)
+	}
+	return 0
+}
+
+// buildMSSQLColumnDefinition - column definition from a sp_columns row
 func buildMSSQLColumnDefinition(col []interface{}) ColumnDefinition {
 	dataname := col[3].(string)
-	// replace '%' char
-	dataname = strings.ReplaceAll(dataname, "%", "%")
-	datatype := col[5].(string)
-	switch strings.ToLower(datatype) {
-	case "numeric":
-		if scale := col[8].(int64); 0 < scale {
-			datatype = "double"
-		} else if precision := col[6].(int64); 9 < precision {
-			datatype = "bigint"
-		} else {
-			datatype = "int"
-		}
-	case "varchar":
-		if datalen := col[7].(int64); datalen < 400 {
-			datatype = fmt.Sprintf("varchar(%d)", datalen)
-		} else {
-			datatype = "text"
-		}
-	case "nvarchar":
-		datatype = fmt.Sprintf("varchar(%d)", col[7].(int64))
-	case "nchar":
-		datatype = fmt.Sprintf("char(%d)", col[7].(int64))
-	case "ntext":
-		datatype = "text"
-	case "datetime":
-		datatype = "datetime"
-	case "datetime2":
-		datatype = "datetime"
-	default:
-		datatype = fmt.Sprintf("%s(%d)", datatype, col[7].(int64))
+	// identity columns are reported as "int identity" or "numeric() identity"
+	typename := strings.ToLower(col[5].(string))
+	identity := strings.HasSuffix(typename, " identity")
+	source := mssqlType{
+		Name:      strings.TrimSuffix(strings.TrimSuffix(typename, " identity"), "()"),
+		Precision: columnInt(col[6]),
+		Scale:     columnInt(col[8]),
+	}
+	// COLUMN_DEF
+	def := ""
+	if 12 < len(col) && col[12] != nil {
+		def = col[12].(string)
 	}
 
 	return ColumnDefinition{
-		Name:     dataname,
-		DataType: datatype,
-		Nullable: 0 < col[10].(int64),
+		Name:       dataname,
+		DataType:   mapMSSQLType(source),
+		Nullable:   0 < columnInt(col[10]),
+		SourceType: source.String(),
+		Identity:   identity,
+		Default:    def,
 	}
 }
 
+// readMSSQLComputedColumns - mark the computed columns of the source table
+func readMSSQLComputedColumns(db *sql.DB, owner string, table string, columns []ColumnDefinition) []ColumnDefinition {
+	query := "SELECT name, definition, is_persisted FROM sys.computed_columns WHERE object_id=OBJECT_ID(@p1)"
+	rows, err := queryFetchAll(db, query, fmt.Sprintf("%s.%s", quoteMSSQL(owner), quoteMSSQL(table)))
+	if err != nil {
+		fmt.Println(err.Error())
+		return columns
+	}
+	for _, row := range rows {
+		for i, col := range columns {
+			if strings.EqualFold(col.Name, row[0].(string)) {
+				columns[i].Computed = true
+				columns[i].Expression = row[1].(string)
+				columns[i].Persisted = row[2].(bool)
+			}
+		}
+	}
+	return columns
+}
+
 func buildMySQLColumnDefinition(col []interface{}) ColumnDefinition {
-	name := string(col[0].([]uint8))
-	datatype := string(col[1].([]uint8))
-	nullable := string(col[2].([]uint8)) != "YES"
+	name := transformString(col[0])
+	datatype := transformString(col[1])
+	nullable := transformString(col[2]) == "YES"
 	return ColumnDefinition{
 		Name:     name,
 		DataType: datatype,
@@ -190,81 +320,158 @@ func buildMySQLColumnDefinition(col []interface{}) ColumnDefinition {
 	}
 }
 
-//
-func readMSSQLTableColumns(db *sql.DB, table string) []ColumnDefinition {
-	return readTableColumns(db, table, "sp_columns ", buildMSSQLColumnDefinition)
+// readMSSQLTableColumns - columns of the source table owned by the schema(owner)
+func readMSSQLTableColumns(db *sql.DB, owner string, table string) []ColumnDefinition {
+	return readTableColumns(db, "EXEC sp_columns @table_name=@p1, @table_owner=@p2", buildMSSQLColumnDefinition, table, owner)
 }
 
 func readMySQLTableColumns(db *sql.DB, table string) []ColumnDefinition {
-	return readTableColumns(db, table, "SHOW COLUMNS FROM ", buildMySQLColumnDefinition)
+	return readTableColumns(db, fmt.Sprintf("SHOW COLUMNS FROM %s", quoteMySQL(table)), buildMySQLColumnDefinition)
 }
 
-func matchTableColumns(left []ColumnDefinition, right []ColumnDefinition) bool {
-	return true
+// matchTableColumns - whether the target columns match the expected columns
+func matchTableColumns(expected []ColumnDefinition, live []ColumnDefinition) bool {
+	return len(diffColumns(expected, live)) <= 0
 }
 
-func buildMySQLTable(db *sql.DB, table string, columns []ColumnDefinition) {
-	cols := make([]string, len(columns))
-
-	for i, col := range columns {
-		options := ""
-		if !col.Nullable {
-			options = "NOT NULL"
-		}
+// sourceTable - qualified source table name, [owner].[table]
+func (tt TransferTask) sourceTable() string {
+	return fmt.Sprintf("[%s].[%s]", tt.Setting.Owner(), tt.Setting.Name)
+}
 
-		cols[i] = fmt.Sprintf("`%s` %s %s",
-			strings.ReplaceAll(col.Name, "%", ""),
-			col.DataType,
-			options)
+// targetTable - target table name, the source table name unless mapped
+func (tt TransferTask) targetTable() string {
+	if 0 < len(tt.Setting.Target) {
+		return tt.Setting.Target
 	}
+	return tt.Setting.Name
+}
 
-	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (%s)",
-		table, strings.Join(cols, ","))
-	fmt.Printf(query)
-	tx, _ := db.Begin()
-	tx.Exec(query)
-	defer tx.Commit()
+// prepareColumns - read the source columns and map them to the target,
+// fails when the target names collide or a transform does not apply
+func (tt *TransferTask) prepareColumns() error {
+	columns := readMSSQLTableColumns(tt.Source, tt.Setting.Owner(), tt.Setting.Name)
+	columns = readMSSQLComputedColumns(tt.Source, tt.Setting.Owner(), tt.Setting.Name, columns)
+	for _, col := range columns {
+		if strings.EqualFold(col.Name, tt.Setting.Index) {
+			tt.IndexColumn = col
+		}
+	}
+	mappings, err := nameColumns(mapColumns(columns, tt.Setting.Columns), tt.Setting.Columns.Rename, tt.Setting.Naming)
+	if err != nil {
+		return fmt.Errorf("%s: %s", tt.sourceTable(), err.Error())
+	}
+	mappings = overrideTypes(mappings, tt.Setting.Types, tt.Setting.TypeOverrides)
+	if mappings, err = transformColumns(mappings, tt.Setting.Transforms); err != nil {
+		return fmt.Errorf("%s: %s", tt.sourceTable(), err.Error())
+	}
+	tt.Columns = translateColumns(mappings, tt.Setting.ComputedColumns)
+	tt.prepareIndexes()
+	return nil
 }
 
-func (tt TransferTask) duplicateTable() {
-	// load ColumnDefinitions
-	oldColumns := readMSSQLTableColumns(tt.Source, tt.Setting.Name)
-	newColumns := readMySQLTableColumns(tt.Target, tt.Setting.Name)
+// prepareIndexes - read the source keys and map them to the target columns
+func (tt *TransferTask) prepareIndexes() {
+	primary := readMSSQLPrimaryKey(tt.Source, tt.Setting.Owner(), tt.Setting.Name)
+	indexes := readMSSQLIndexes(tt.Source, tt.Setting.Owner(), tt.Setting.Name)
+	tt.PrimaryKey, tt.Indexes = mapIndexes(tt.Columns, primary, indexes)
+	tt.PrimaryKey, tt.Indexes = demoteLongKeys(tt.PrimaryKey, tt.Indexes, tt.targetColumns(), tt.Setting.Charset)
 
-	if len(newColumns) <= 0 {
-		// has no table on target, build new
-		buildMySQLTable(tt.Target, tt.Setting.Name, oldColumns)
-	} else if !matchTableColumns(oldColumns, newColumns) {
-		// TODO: alter the table
+	if tt.Setting.IndexWatermark {
+		if index, missing := watermarkIndex(tt.indexTargetName(), tt.PrimaryKey, tt.Indexes); missing {
+			tt.Indexes = append(tt.Indexes, index)
+		}
+	}
+	// AUTO_INCREMENT columns have to lead a key
+	for _, m := range tt.Columns {
+		if m.Target.Identity {
+			if index, missing := leadingIndex(IdentityIndexName, m.Target.Name, tt.PrimaryKey, tt.Indexes); missing {
+				tt.Indexes = append(tt.Indexes, index)
+			}
+		}
 	}
 }
 
-type ReplacePattern struct {
-	Pattern *regexp.Regexp
-	Replace string
+// targetColumns - column definitions on the target
+func (tt TransferTask) targetColumns() []ColumnDefinition {
+	columns := make([]ColumnDefinition, len(tt.Columns))
+	for i, m := range tt.Columns {
+		columns[i] = m.Target
+	}
+	return columns
 }
 
-var replacePatterns = []ReplacePattern{
-	{regexp.MustCompile(`(?i)create\sview`), "CREATE VIEW"},
-	{regexp.MustCompile(`(?i)\[?dbo\]?\.`), ""},
-	{regexp.MustCompile(`(?i)%`), ""},
-	{regexp.MustCompile(`(?i)\[([^\]\[]+)\]`), "`$1`"},
+// copiedColumns - mapped columns the rows are copied into, generated columns are left out
+func (tt TransferTask) copiedColumns() []ColumnMapping {
+	copied := make([]ColumnMapping, 0, len(tt.Columns))
+	for _, m := range tt.Columns {
+		if !m.Target.Computed {
+			copied = append(copied, m)
+		}
+	}
+	return copied
 }
 
-func (rp ReplacePattern) ReplaceAll(s string) string {
-	return rp.Pattern.ReplaceAllString(s, rp.Replace)
+// selectColumns - source columns to read, with the index column appended when it is not copied
+func (tt TransferTask) selectColumns() []string {
+	copied := tt.copiedColumns()
+	names := make([]string, 0, len(copied)+1)
+	for _, m := range copied {
+		names = append(names, m.Source.Name)
+	}
+	if 0 < len(tt.Setting.Index) && !containsName(names, tt.Setting.Index) {
+		names = append(names, tt.Setting.Index)
+	}
+	return names
 }
 
-func copyViewQuery(old string) string {
-	// viewname := args[0].(string)
-	// definition := args[1].(string)
-	query := old
-	// retrieve patterns
-	for _, rep := range replacePatterns {
-		query = rep.ReplaceAll(query)
+// tableDefinition - target table definition of the mapped columns
+func (tt TransferTask) tableDefinition() TableDefinition {
+	return TableDefinition{
+		Name:      tt.targetTable(),
+		Columns:   tt.targetColumns(),
+		Charset:   tt.Setting.Charset,
+		Collation: tt.Setting.Collation,
+
+		PrimaryKey: tt.PrimaryKey,
+		Indexes:    tt.Indexes,
+	}
+}
+
+func (tt *TransferTask) duplicateTable() error {
+	if tt.Columns == nil {
+		if err := tt.prepareColumns(); err != nil {
+			fmt.Println(err.Error())
+			return err
+		}
 	}
+	// load ColumnDefinitions
+	oldColumns := tt.targetColumns()
+	newColumns := readMySQLTableColumns(tt.Target, tt.targetTable())
 
-	return query
+	if len(newColumns) <= 0 {
+		// has no table on target, build new
+		if err := buildMySQLTable(tt.Target, tt.tableDefinition()); err != nil {
+			fmt.Println(err.Error())
+			return err
+		}
+		return nil
+	}
+	addMissingIndexes(tt.Target, tt.tableDefinition())
+	// existing table has to hold unicode source columns
+	for _, w := range charsetWarnings(tt.Columns, readMySQLColumnCharsets(tt.Target, tt.targetTable())) {
+		fmt.Printf("    WARN %s\n", w)
+	}
+	if !matchTableColumns(oldColumns, newColumns) {
+		// TODO: alter the table
+		fmt.Printf("    WARN columns of %s differ from the source, see schema diff\n", tt.targetTable())
+	}
+	return nil
+}
+
+// copyViewQuery - MySQL definition of the T-SQL view
+func copyViewQuery(old string) string {
+	return translateTSQL(old)
 }
 
 func listMSSQLViews(source *sql.DB) map[string]string {
@@ -285,6 +492,20 @@ func listMSSQLViews(source *sql.DB) map[string]string {
 	return rets
 }
 
+// readMSSQLViewOwners - source schema (owner) of the source views, by the view(key)
+func readMSSQLViewOwners(source *sql.DB) map[string]string {
+	rows, err := queryFetchAll(source, "SELECT name, SCHEMA_NAME(schema_id) FROM sys.views")
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil
+	}
+	owners := make(map[string]string, len(rows))
+	for _, row := range rows {
+		owners[transformString(row[0])] = transformString(row[1])
+	}
+	return owners
+}
+
 func listMySQLViews(target *sql.DB, db string) map[string]string {
 	query := "SELECT TABLE_NAME, VIEW_DEFINITION from information_schema.views WHERE table_schema LIKE ?"
 	rets := make(map[string]string)
@@ -303,22 +524,19 @@ func listMySQLViews(target *sql.DB, db string) map[string]string {
 	return rets
 }
 
-// duplicate views
-func duplicateView(source *sql.DB, target *sql.DB, db string) {
-	// list source views
-	oldViews := listMSSQLViews(source)
-	newViews := listMySQLViews(target, db)
-
-	for vname, def := range oldViews {
-		if _, exists := newViews[vname]; !exists {
-			if rs, err := target.Exec(copyViewQuery(def)); err != nil {
-				fmt.Errorf(err.Error())
-			} else {
-				affected, _ := rs.RowsAffected()
-				fmt.Printf("%d rows affected", affected)
-			}
+// renameViewColumns - quoted identifiers of the view named by the recorded target columns.
+// identifiers not recorded are normalized by the strategy when they hold unsafe characters
+func renameViewColumns(query string, columns map[string]string, naming NamingSetting) string {
+	return identifierPattern.ReplaceAllStringFunc(query, func(quoted string) string {
+		name := strings.ReplaceAll(quoted[1:len(quoted)-1], "``", "`")
+		if target, exists := columns[strings.ToLower(name)]; exists {
+			return quoteMySQL(target)
 		}
-	}
+		if strings.IndexFunc(name, func(r rune) bool { return !isSafeNameRune(r) }) < 0 {
+			return quoted
+		}
+		return quoteMySQL(naming.Normalize(name))
+	})
 }
 
 func (tt TransferTask) findSuccessColumnIndex(rss *sql.Rows, index string) int {
@@ -331,14 +549,134 @@ func (tt TransferTask) findSuccessColumnIndex(rss *sql.Rows, index string) int {
 	return -1
 }
 
-func (tt *TransferTask) copyRows() int {
-	// FROM Latest success
-	selects := fmt.Sprintf("SELECT * FROM [%s] WHERE @p1<[%s] ORDER BY [%s] ASC", tt.Setting.Name, tt.Setting.Index, tt.Setting.Index)
+// selectQuery - source query reading the rows after the latest success,
+// every row when the task has no index
+func (tt TransferTask) selectQuery() string {
+	names := tt.selectColumns()
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = quoteMSSQL(n)
+	}
+	if len(tt.Setting.Index) <= 0 {
+		query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ","), tt.sourceTable())
+		if 0 < len(tt.Setting.Where) {
+			query += fmt.Sprintf(" WHERE (%s)", tt.Setting.Where)
+		}
+		return query
+	}
+	index := quoteMSSQL(tt.Setting.Index)
+	where := fmt.Sprintf("@p1<%s", index)
+	if 0 < len(tt.Setting.Where) {
+		where = fmt.Sprintf("%s AND (%s)", where, tt.Setting.Where)
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s ASC",
+		strings.Join(quoted, ","), tt.sourceTable(), where, index)
+}
+
+// watermarkLayouts - layouts to read the watermark written by hand in the successor
+var watermarkLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// rewind - the watermark rewound by the lookback window.
+// returns false when no lookback applies
+func (tt TransferTask) rewind() (interface{}, bool) {
+	if len(tt.Setting.Lookback) <= 0 {
+		return tt.Success, false
+	}
+	lookback, err := time.ParseDuration(tt.Setting.Lookback)
+	if err != nil {
+		fmt.Printf("invalid lookback %s: %s\n", tt.Setting.Lookback, err.Error())
+		return tt.Success, false
+	}
+
+	switch success := tt.Success.(type) {
+	case time.Time:
+		return success.Add(-lookback), true
+	case string:
+		for _, layout := range watermarkLayouts {
+			if at, err := time.Parse(layout, success); err == nil {
+				return at.Add(-lookback), true
+			}
+		}
+	}
+	fmt.Printf("lookback ignored, watermark %v is not a time\n", tt.Success)
+	return tt.Success, false
+}
+
+// indexTargetName - target column of the index, empty when not copied
+func (tt TransferTask) indexTargetName() string {
+	for _, m := range tt.Columns {
+		if strings.EqualFold(m.Source.Name, tt.Setting.Index) {
+			return m.Target.Name
+		}
+	}
+	return ""
+}
+
+// deleteQuery - target statement removing the rows to read again
+func (tt TransferTask) deleteQuery() string {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s>?",
+		quoteMySQL(tt.targetTable()), quoteMySQL(tt.indexTargetName()))
+}
+
+// insertQuery - target statement inserting the mapped columns of a row
+func (tt TransferTask) insertQuery() string {
+	copied := tt.copiedColumns()
+	names := make([]string, len(copied))
+	params := make([]string, len(copied))
+	for i, m := range copied {
+		names[i] = quoteMySQL(m.Target.Name)
+		params[i] = "?"
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteMySQL(tt.targetTable()), strings.Join(names, ","), strings.Join(params, ","))
+	if 0 < len(tt.PrimaryKey) {
+		// upsert on the primary key
+		updates := make([]string, len(names))
+		for i, name := range names {
+			updates[i] = fmt.Sprintf("%s=VALUES(%s)", name, name)
+		}
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ",")
+	}
+	return query
+}
+
+// copyRows - copy the rows after the latest success and record the new one,
+// fails when the rows are not read, not inserted or not committed
+func (tt *TransferTask) copyRows() (int, error) {
+	if tt.Columns == nil {
+		if err := tt.prepareColumns(); err != nil {
+			fmt.Println(err.Error())
+			return 0, err
+		}
+	}
+	if len(tt.Columns) <= 0 {
+		fmt.Printf("no columns to copy from %s\n", tt.sourceTable())
+		return 0, fmt.Errorf("no columns to copy from %s", tt.sourceTable())
+	}
+	// FROM Latest success, rewound by the lookback
+	from, rewound := tt.rewind()
+	if 0 < len(tt.Setting.Where) {
+		fmt.Printf("    where (%s)\n", tt.Setting.Where)
+	}
+	if rewound {
+		fmt.Printf("    lookback %s from %v\n", tt.Setting.Lookback, from)
+	}
+	selects := tt.selectQuery()
 	// query success index
-	rss, err := tt.Source.Query(selects, tt.Success)
+	param := tt.Zones.watermarkParam(tt.IndexColumn, from)
+	args := make([]interface{}, 0, 1)
+	if 0 < len(tt.Setting.Index) {
+		args = append(args, param)
+	}
+	rss, err := tt.Source.Query(selects, args...)
 	// pass
 	if err != nil {
-		return 0
+		fmt.Println(err.Error())
+		return 0, err
 	}
 	columns, _ := rss.Columns()
 	successIndex := tt.findSuccessColumnIndex(rss, tt.Setting.Index)
@@ -346,42 +684,73 @@ func (tt *TransferTask) copyRows() int {
 
 	// Transaction
 	count := 0
-	insertBase := "INSERT INTO `%s` VALUES (%s)"
-	// build params string
-	params := make([]string, len(columns))
-	for i, _ := range params {
-		params[i] = "?"
-	}
+	// rows not inserted and the first failure, the other rows are copied still
+	failed := 0
+	var failure error
+	inserts := tt.insertQuery()
+	copied := tt.copiedColumns()
 
 	tx, _ := tt.Target.Begin()
+	if rewound {
+		// rows after the rewound watermark are read again, remove them first
+		if len(tt.indexTargetName()) <= 0 {
+			fmt.Printf("    index %s is not copied, re-read rows may duplicate\n", tt.Setting.Index)
+		} else if rs, err := tx.Exec(tt.deleteQuery(), convertValue(tt.IndexColumn, param, tt.Zones)); err == nil {
+			affected, _ := rs.RowsAffected()
+			fmt.Printf("    %d lines removed to read again\n", affected)
+		} else {
+			fmt.Println(err.Error())
+		}
+	}
 	for rss.Next() {
 		count += 1
 		row := scanRow(rss, columns)
-		inserts := fmt.Sprintf(insertBase, tt.Setting.Name, strings.Join(params, ","))
-		tx.Exec(inserts, row...)
+		values := convertRow(copied, append([]interface{}{}, row[:len(copied)]...), tt.Zones)
+		values = transformRow(copied, values)
+		if _, err := tx.Exec(inserts, values...); err != nil {
+			failed += 1
+			if failure == nil {
+				failure = err
+			}
+		}
 
 		// record latest index
-		latest = row[successIndex]
+		if 0 <= successIndex {
+			latest = tt.Zones.watermarkValue(tt.IndexColumn, row[successIndex])
+		}
 		// writes for 20
 		if count%20 == 0 {
-			tx.Commit()
+			if err := tx.Commit(); err != nil && failure == nil {
+				failure = err
+			}
 			// restart transaction
 			tx, _ = tt.Target.Begin()
 		}
 	}
 	// close read
+	readErr := rss.Err()
 	rss.Close()
 
-	fmt.Printf("%d lines copied to %s\n", count, latest)
+	fmt.Printf("%d lines copied to %v\n", count-failed, latest)
 
 	// commit here
-	if err := tx.Commit(); err == nil {
-		// Success. update latest success record
-		tt.Success = latest
-	} else {
+	if err := tx.Commit(); err != nil {
 		fmt.Println(err.Error())
 		// Rollback on Error
 		tx.Rollback()
+		return count, err
+	}
+	if readErr != nil {
+		fmt.Println(readErr.Error())
+		return count, readErr
+	}
+	if failure != nil {
+		// the watermark stays, the rows not copied are read again on the next run
+		err := fmt.Errorf("%d of %d rows not copied to %s: %s", failed, count, tt.targetTable(), failure.Error())
+		fmt.Println(err.Error())
+		return count, err
 	}
-	return count
+	// Success. update latest success record
+	tt.Success = latest
+	return count, nil
 }
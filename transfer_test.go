@@ -2,8 +2,14 @@ package main
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func sampleTransferSettings() map[string][]TransferTask {
@@ -15,13 +21,11 @@ func sampleTransferSettings() map[string][]TransferTask {
 	// return conf.Targets
 	for schema, targets := range conf.Targets {
 		source, _ := OpenConnection(conf.Connectors[KEY_CNX_SOURCE], schema)
-		target, _ := OpenConnection(conf.Connectors[KEY_CNX_TARGET], schema)
+		target, _ := OpenConnection(conf.Connectors[KEY_CNX_TARGET], conf.TargetDatabase(schema))
 		rets[schema] = make([]TransferTask, len(targets))
 		for i, t := range targets {
-			sc, exists := scs[schema][t.Name]
-			if !exists {
-				sc = ""
-			}
+			sc := scs.Watermark(schema, t)
+			t = conf.Resolve(schema, t)
 			rets[schema][i] = TransferTask{
 				Setting: t,
 				Source:  source,
@@ -100,7 +104,7 @@ func TestMSSQLColumnsRead(t *testing.T) {
 		for _, task := range settings {
 			task.Source = source
 			// task.Target = target
-			columns := readMSSQLTableColumns(source, task.Setting.Name)
+			columns := readMSSQLTableColumns(source, task.Setting.Owner(), task.Setting.Name)
 			if len(columns) <= 0 {
 				t.Error("can not find any column")
 			}
@@ -117,11 +121,11 @@ func TestMySQLColumnsRead(t *testing.T) {
 
 	for db, settings := range sampleTransferSettings() {
 		// source, _ := OpenConnection(conf.Connectors[KEY_CNX_SOURCE])
-		target, _ := OpenConnection(conf.Connectors[KEY_CNX_TARGET], db)
+		target, _ := OpenConnection(conf.Connectors[KEY_CNX_TARGET], conf.TargetDatabase(db))
 		defer target.Close()
 
 		for _, task := range settings {
-			columns := readMySQLTableColumns(target, task.Setting.Name)
+			columns := readMySQLTableColumns(target, task.targetTable())
 			if len(columns) <= 0 {
 				t.Error("can not find any column")
 			}
@@ -149,12 +153,14 @@ func TestBuildTable(t *testing.T) {
 	// defer target.Exec("DROP TABLE IF EXISTS " + table)
 
 	columns := []ColumnDefinition{
-		{"id", "int", false},
-		{"name", "varchar(50)", true},
-		{"misc", "text", true},
+		{Name: "id", DataType: "int", Nullable: false},
+		{Name: "name", DataType: "varchar(50)", Nullable: true},
+		{Name: "misc", DataType: "text", Nullable: true},
 	}
 
-	buildMySQLTable(target, table, columns)
+	if err := buildMySQLTable(target, TableDefinition{Name: table, Columns: columns, Charset: DefaultCharset}); err != nil {
+		t.Fatal(err)
+	}
 
 	// test columns
 	cols := readMySQLTableColumns(target, table)
@@ -191,7 +197,9 @@ func TestDuplicateTableColumns(t *testing.T) {
 	// before start, clear previous database
 	tt.Target.Exec("DROP TABLE IF EXISTS " + tt.Setting.Name)
 
-	tt.duplicateTable()
+	if err := tt.duplicateTable(); err != nil {
+		t.Fatal(err)
+	}
 	columns := readMySQLTableColumns(tt.Target, tt.Setting.Name)
 	if len(columns) <= 0 {
 		t.Errorf("table not exists")
@@ -252,14 +260,16 @@ func TestTableSuccess(t *testing.T) {
 	tt.Target.Exec("DROP TABLE IF EXISTS " + tt.Setting.Name)
 
 	// table needed
-	tt.duplicateTable()
+	if err := tt.duplicateTable(); err != nil {
+		t.Fatal(err)
+	}
 
 	if 0 < len(tt.Success.(string)) {
 		t.Error("success set before starts")
 	}
 
-	cnt := tt.copyRows()
-	if cnt <= 0 {
+	cnt, err := tt.copyRows()
+	if err != nil || cnt <= 0 {
 		t.Error("data had not transferred")
 	}
 
@@ -309,41 +319,194 @@ type PatternSample struct {
 func TestReplacePatterns(t *testing.T) {
 
 	samples := []PatternSample{
-		{"create view", "CREATE VIEW IF NOT EXISTS"},
-		{"CREATE view ", "CREATE VIEW IF NOT EXISTS "},
+		{"create view", "CREATE VIEW"},
+		{"CREATE view ", "CREATE VIEW "},
 		{"[dbo].[cheil]", "`cheil`"},
 		{"[a],[b],[c]", "`a`,`b`,`c`"},
+		{"SELECT '[a] 25%' AS [b]", "SELECT '[a] 25%' AS `b`"},
 	}
 
 	for i, s := range samples {
-		val := s.Sample
-		t.Logf("[%d] %s ---", i, val)
-		for j, p := range replacePatterns {
-			val = p.ReplaceAll(val)
-			t.Logf("  %d>> %s", j, val)
+		if val := copyViewQuery(s.Sample); s.Expect != val {
+			t.Errorf("[%d] expected %s but %s", i, s.Expect, val)
+		}
+	}
+}
+
+var updateViews = flag.Bool("update", false, "rewrite the expected MySQL views of the view corpus")
+
+const viewCorpusPath = "./tests/views" // T-SQL views(.tsql) and their expected MySQL(.mysql.sql)
+
+// viewCorpusTask - target names of the objects the views of the view corpus select from
+var viewCorpusTask = ViewTask{
+	Schema:    "CheilOptimizer_DM",
+	Views:     map[string]string{"campaign_summary": "dm_campaign_summary"},
+	Databases: map[string]string{"cheiloptimizer_dm": "dm", "cheil_master": "master_replica"},
+	Tables: map[string]string{"cheiloptimizer_dm.dbo.cleansed_dataset": "legacy_cleansed_dataset",
+		"cheil_master.dbo.country": "master_country"},
+}
+
+// TestViewCorpus translates the views of the corpus, go test -run TestViewCorpus -update rewrites the expected
+func TestViewCorpus(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join(viewCorpusPath, "*.tsql"))
+	if len(paths) <= 0 {
+		t.Fatalf("no views in %s", viewCorpusPath)
+	}
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Error(err)
+			continue
 		}
-		if s.Expect != val {
-			t.Errorf(">>>expected %s but %s", s.Expect, val)
+		translated := translateView(strings.ReplaceAll(string(contents), "\r\n", "\n"), viewCorpusTask.objectNames())
+		expectPath := strings.TrimSuffix(path, ".tsql") + ".mysql.sql"
+		if *updateViews {
+			if err = ioutil.WriteFile(expectPath, []byte(translated), 0666); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		expect, err := ioutil.ReadFile(expectPath)
+		if err != nil {
+			t.Errorf("%s: %s", path, err.Error())
+			continue
+		}
+		if translated != strings.ReplaceAll(string(expect), "\r\n", "\n") {
+			t.Errorf("%s expected\n%s\nbut\n%s", path, expect, translated)
 		}
 	}
 }
 
-func TestCreateViewQuery(t *testing.T) {
-	sample := `CREATE VIEW [dbo].[Angola_52_jinyeong.kim_cheil.com] AS
-	SELECT  Date, [Campaign name], [Destination URL], [Segment name], [Creative name], [Publisher platform], [Planned cost_usd], [Planned cost_local], [Actual cost_usd], [Actual cost_local], [Planned Impressions], 
-				   Impressions, [Viewable impression], [Planned CPM_usd], [Planned CPM_local], [Actual CPM_usd], [Actual CPM_local], [Planned Clicks], Clicks, [Planned CTR], CTR, [Planned CPC_usd], 
-				   [Planned CPC_local], [Actual CPC_usd], [Actual CPC_local], [Planned Reach], Reach, Frequency, [Planned Views_video], Views_video, [Video watches at 25%], [Video watches at 50%], 
-				   [Video watches at 75%], [Video watches at 100%], [Link clicks], [Post engagements], [Post comments], [Post shares], [Post Likes], CID, [CID 1], [CID 2], [CID 3], [CID 4], [CID 5], [CID 6], [CID 7], 
-				   [CID 8], [CID 9], [CID 10], [CID 11], [CID 12], [CrawlMediaAccount], [CrawlMediaCode], [Planned CPV_views_usd], [Planned CPV_views_local], [Actual CPV_views_usd], 
-				   [Actual CPV_views_local], [Post click conversions], [Post view conversions], [Total conversions], Follows, Retweets, Replies, [Planned VTR], VTR, [Engagement rate], Sent, Delivered, 
-				   [Delivered rate], [Open], [Open rate], Display, [Display rate], [Clicks owned], CTR_delivered, CTR_display, CTOR, Campaign_name, Country, Campaign_start_dt, Campaign_end_dt, Cycle, 
-				   Currency, Product, Advertiser, Description, [Week in campaign], Media_type, Media_name, Media_campaign, Confirm_user, confirm_date, reg_date, [Tracking code], [Planned visits], visits, 
-				   [Planned C2V], C2V, [Planned CPV_visits_usd], [Planned CPV_visits_local], [Actual CPV_visits_usd], [Actual CPV_visits_local], [Unique visitors], [Page views], [Bounce rate], 
-				   [Average time spent], [Planned Add to cart], [Add to cart], [Planned CVR], CVR, [Planned CPA_usd], [Planned CPA_local], [Actual CPA_usd], [Actual CPA_local], Checkout, Orders, Units, 
-				   Revenue_usd, Revenue_local, ROAS_usd, ROAS_local
-	FROM     [CheilOptimizer_DM].dbo.Cleansed_Dataset
-	WHERE  (Campaign_name = '52' AND Country = 'AO' AND [DATE] IS NOT NULL)`
-	changed := copyViewQuery(sample)
-	t.Log(changed)
+func TestMapColumns(t *testing.T) {
+	columns := []ColumnDefinition{
+		{Name: "Date", DataType: "datetime", Nullable: true},
+		{Name: "Planned CPM_usd", DataType: "double", Nullable: true},
+		{Name: "Video watches at 25%", DataType: "int", Nullable: true},
+		{Name: "audit_user", DataType: "varchar(50)", Nullable: true},
+	}
+
+	mappings := mapColumns(columns, ColumnSelection{
+		Exclude: []string{"AUDIT_USER"},
+		Rename:  map[string]string{"planned cpm_usd": "planned_cpm_usd"},
+	})
+	expects := []string{"Date", "planned_cpm_usd", "Video watches at 25%"}
+	if len(mappings) != len(expects) {
+		t.Fatalf("expected %d columns but %d", len(expects), len(mappings))
+	}
+	for i, m := range mappings {
+		if m.Target.Name != expects[i] {
+			t.Errorf("[%d] expected %s but %s", i, expects[i], m.Target.Name)
+		}
+		if m.Source.Name != columns[i].Name {
+			t.Errorf("[%d] source name changed to %s", i, m.Source.Name)
+		}
+	}
+
+	included := mapColumns(columns, ColumnSelection{Include: []string{"date"}})
+	if len(included) != 1 || included[0].Target.Name != "Date" {
+		t.Errorf("include expected only Date but %v", included)
+	}
+}
+
+func TestTransferQueries(t *testing.T) {
+	tt := TransferTask{
+		Setting: TableTransferSetting{Name: "Cleansed_Dataset", Index: "insert_dt", Target: "cleansed"},
+		Columns: mapColumns([]ColumnDefinition{
+			{Name: "Campaign name", DataType: "varchar(50)", Nullable: true},
+		}, ColumnSelection{Rename: map[string]string{"Campaign name": "campaign_name"}}),
+	}
 
+	selects := "SELECT [Campaign name],[insert_dt] FROM [dbo].[Cleansed_Dataset] WHERE @p1<[insert_dt] ORDER BY [insert_dt] ASC"
+	if q := tt.selectQuery(); q != selects {
+		t.Errorf("select expected %s but %s", selects, q)
+	}
+	inserts := "INSERT INTO `cleansed` (`campaign_name`) VALUES (?)"
+	if q := tt.insertQuery(); q != inserts {
+		t.Errorf("insert expected %s but %s", inserts, q)
+	}
+
+	// every row without an index
+	tt.Setting.Index, tt.Setting.Where = "", "Country = 'AO'"
+	selects = "SELECT [Campaign name] FROM [dbo].[Cleansed_Dataset] WHERE (Country = 'AO')"
+	if q := tt.selectQuery(); q != selects {
+		t.Errorf("select expected %s but %s", selects, q)
+	}
+}
+
+func TestTransferFilters(t *testing.T) {
+	at := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	tt := TransferTask{
+		Setting: TableTransferSetting{Name: "Cleansed_Dataset", Index: "insert_dt", Where: "Country = 'AO'", Lookback: "48h"},
+		Success: at,
+		Columns: mapColumns([]ColumnDefinition{
+			{Name: "insert_dt", DataType: "datetime", Nullable: false},
+		}, ColumnSelection{}),
+	}
+
+	selects := "SELECT [insert_dt] FROM [dbo].[Cleansed_Dataset] WHERE @p1<[insert_dt] AND (Country = 'AO') ORDER BY [insert_dt] ASC"
+	if q := tt.selectQuery(); q != selects {
+		t.Errorf("select expected %s but %s", selects, q)
+	}
+
+	from, rewound := tt.rewind()
+	if !rewound || !from.(time.Time).Equal(at.Add(-48*time.Hour)) {
+		t.Errorf("rewind expected %v but %v", at.Add(-48*time.Hour), from)
+	}
+
+	tt.Success = "2021-05-10 12:00:00"
+	if from, rewound = tt.rewind(); !rewound || !from.(time.Time).Equal(at.Add(-48*time.Hour)) {
+		t.Errorf("rewind from string expected %v but %v", at.Add(-48*time.Hour), from)
+	}
+
+	tt.Success = int64(1024)
+	if from, rewound = tt.rewind(); rewound || from != tt.Success {
+		t.Errorf("rewind on number expected to be ignored but %v", from)
+	}
+
+	deletes := "DELETE FROM `Cleansed_Dataset` WHERE `insert_dt`>?"
+	if q := tt.deleteQuery(); q != deletes {
+		t.Errorf("delete expected %s but %s", deletes, q)
+	}
+}
+
+func TestUpsertQuery(t *testing.T) {
+	tt := TransferTask{
+		Setting:    TableTransferSetting{Name: "Cleansed_Dataset", Index: "insert_dt"},
+		Columns:    mapColumns([]ColumnDefinition{{Name: "id"}, {Name: "name"}}, ColumnSelection{}),
+		PrimaryKey: []string{"id"},
+	}
+	inserts := "INSERT INTO `Cleansed_Dataset` (`id`,`name`) VALUES (?,?) ON DUPLICATE KEY UPDATE `id`=VALUES(`id`),`name`=VALUES(`name`)"
+	if q := tt.insertQuery(); q != inserts {
+		t.Errorf("insert expected %s but %s", inserts, q)
+	}
+}
+
+func TestCopyRowsWatermark(t *testing.T) {
+	tt := TransferTask{
+		Setting:     TableTransferSetting{Name: "orders", Index: "id", Target: "orders"},
+		Columns:     mapColumns([]ColumnDefinition{{Name: "id", DataType: "int", SourceType: "int"}}, ColumnSelection{}),
+		IndexColumn: ColumnDefinition{Name: "id", DataType: "int", SourceType: "int"},
+		Success:     int64(10),
+	}
+	sql.Register("fake_copy_source", fakeDriver{tt.selectQuery(): {
+		columns: []string{"id"},
+		values:  [][]driver.Value{{int64(11)}, {int64(12)}},
+	}})
+	sql.Register("fake_copy_failing", fakeDriver{})
+	sql.Register("fake_copy_target", fakeDriver{tt.insertQuery(): {}})
+	tt.Source, _ = sql.Open("fake_copy_source", "")
+	defer tt.Source.Close()
+
+	// inserts rejected on the target keep the watermark
+	tt.Target, _ = sql.Open("fake_copy_failing", "")
+	defer tt.Target.Close()
+	if count, err := tt.copyRows(); err == nil || count != 2 || tt.Success != int64(10) {
+		t.Errorf("watermark expected to stay on failed inserts but %v %d %v", tt.Success, count, err)
+	}
+
+	tt.Target, _ = sql.Open("fake_copy_target", "")
+	defer tt.Target.Close()
+	if _, err := tt.copyRows(); err != nil || tt.Success != int64(12) {
+		t.Errorf("watermark expected 12 but %v %v", tt.Success, err)
+	}
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	TransformHash     = "hash_sha256" // salted sha256 hex digest
+	TransformMask     = "mask"        // replace characters with '*', keeping the leading ones
+	TransformNull     = "null"        // drop the value
+	TransformTruncate = "truncate"    // cut the value to the length
+	TransformLower    = "lower"       // lower case
+)
+
+// ColumnTransform - value transform applied to a column before the insert
+type ColumnTransform struct {
+	Type   string `yaml:"type"`   // one of hash_sha256, mask, null, truncate, lower
+	Salt   string `yaml:"salt"`   // hash_sha256 salt, Settings.Salt when empty. required by hash_sha256
+	Length int    `yaml:"length"` // truncate length in characters
+	Keep   int    `yaml:"keep"`   // mask, leading characters to keep
+}
+
+// Valid - whether the transform type is known
+func (ct ColumnTransform) Valid() bool {
+	switch ct.Type {
+	case TransformHash, TransformMask, TransformNull, TransformTruncate, TransformLower:
+		return true
+	}
+	return false
+}
+
+// Column - target column definition holding the transformed values
+func (ct ColumnTransform) Column(def ColumnDefinition) ColumnDefinition {
+	switch ct.Type {
+	case TransformHash:
+		def.DataType = "char(64)"
+	case TransformNull:
+		def.Nullable = true
+	case TransformTruncate:
+		if 0 < ct.Length {
+			def.DataType = fmt.Sprintf("varchar(%d)", ct.Length)
+		}
+	}
+	return def
+}
+
+// transformString - string form of the scanned value
+func transformString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	}
+	return fmt.Sprint(v)
+}
+
+// maskString - mask the characters after keep, e-mail domains are kept
+func maskString(s string, keep int) string {
+	domain := ""
+	if at := strings.LastIndex(s, "@"); 0 < at {
+		s, domain = s[:at], s[at:]
+	}
+	runes := []rune(s)
+	for i := range runes {
+		if keep <= i {
+			runes[i] = '*'
+		}
+	}
+	return string(runes) + domain
+}
+
+// Apply - transform the scanned value, NULL stays NULL
+func (ct ColumnTransform) Apply(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch ct.Type {
+	case TransformHash:
+		sum := sha256.Sum256([]byte(ct.Salt + transformString(v)))
+		return hex.EncodeToString(sum[:])
+	case TransformMask:
+		return maskString(transformString(v), ct.Keep)
+	case TransformNull:
+		return nil
+	case TransformTruncate:
+		if runes := []rune(transformString(v)); ct.Length < len(runes) {
+			return string(runes[:ct.Length])
+		}
+	case TransformLower:
+		return strings.ToLower(transformString(v))
+	}
+	return v
+}
+
+// transformColumns - attach the configured transforms(by source column) to the mappings.
+// fails on an unknown transform, a hash without salt or a column not copied, so values are never copied unmasked
+func transformColumns(mappings []ColumnMapping, transforms map[string]ColumnTransform) ([]ColumnMapping, error) {
+	for name, ct := range transforms {
+		if !ct.Valid() {
+			return nil, fmt.Errorf("unknown transform %s on column %s", ct.Type, name)
+		}
+		if ct.Type == TransformHash && len(ct.Salt) <= 0 {
+			return nil, fmt.Errorf("%s on column %s takes a salt", ct.Type, name)
+		}
+		found := false
+		for i, m := range mappings {
+			if strings.EqualFold(m.Source.Name, name) {
+				transform := ct
+				mappings[i].Transform = &transform
+				mappings[i].Target = ct.Column(m.Target)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("transform %s on column %s matches no copied column", ct.Type, name)
+		}
+	}
+	return mappings, nil
+}
+
+// transformRow - apply the column transforms to the row values in mapping order
+func transformRow(mappings []ColumnMapping, row []interface{}) []interface{} {
+	for i, m := range mappings {
+		if m.Transform != nil {
+			row[i] = m.Transform.Apply(row[i])
+		}
+	}
+	return row
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+)
+
+type TransformSample struct {
+	Transform ColumnTransform
+	Value     interface{}
+	Expect    interface{}
+}
+
+func TestTransformApply(t *testing.T) {
+	samples := []TransformSample{
+		{ColumnTransform{Type: TransformHash, Salt: "salt"}, "jinyeong.kim@cheil.com", "a32213a1e2a53797f3e7ff07212e011a0fda03b52615d1e26a2159bab46459b0"},
+		{ColumnTransform{Type: TransformHash, Salt: "salt"}, nil, nil},
+		{ColumnTransform{Type: TransformMask, Keep: 2}, "jinyeong.kim@cheil.com", "ji**********@cheil.com"},
+		{ColumnTransform{Type: TransformMask}, []byte("김진영"), "***"},
+		{ColumnTransform{Type: TransformNull}, "anything", nil},
+		{ColumnTransform{Type: TransformTruncate, Length: 3}, "안녕하세요", "안녕하"},
+		{ColumnTransform{Type: TransformTruncate, Length: 10}, "short", "short"},
+		{ColumnTransform{Type: TransformLower}, "Jinyeong.Kim@Cheil.com", "jinyeong.kim@cheil.com"},
+	}
+
+	for i, s := range samples {
+		if v := s.Transform.Apply(s.Value); v != s.Expect {
+			t.Errorf("[%d] %s expected %v but %v", i, s.Transform.Type, s.Expect, v)
+		}
+	}
+}
+
+func TestTransformColumns(t *testing.T) {
+	mappings := mapColumns([]ColumnDefinition{
+		{Name: "email", DataType: "varchar(200)", Nullable: false},
+		{Name: "memo", DataType: "text", Nullable: false},
+		{Name: "name", DataType: "varchar(50)", Nullable: false},
+	}, ColumnSelection{})
+	mappings, err := transformColumns(mappings, map[string]ColumnTransform{
+		"EMAIL": {Type: TransformHash, Salt: "salt"},
+		"memo":  {Type: TransformNull},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mappings[0].Transform == nil || mappings[0].Target.DataType != "char(64)" {
+		t.Errorf("hash column expected char(64) but %s", mappings[0].Target.DataType)
+	}
+	if mappings[0].Source.DataType != "varchar(200)" {
+		t.Errorf("source column changed to %s", mappings[0].Source.DataType)
+	}
+	if !mappings[1].Target.Nullable {
+		t.Error("null column expected nullable")
+	}
+	if mappings[2].Transform != nil {
+		t.Error("transform attached to name")
+	}
+
+	row := transformRow(mappings, []interface{}{"a@b.c", "memo", "name"})
+	if row[1] != nil || row[2] != "name" {
+		t.Errorf("transformed row %v", row)
+	}
+}
+
+func TestTransformColumnsRejected(t *testing.T) {
+	columns := []ColumnDefinition{{Name: "email", DataType: "varchar(200)"}}
+	samples := map[string]map[string]ColumnTransform{
+		"unknown":   {"email": {Type: "unknown"}},
+		"unsalted":  {"email": {Type: TransformHash}},
+		"unmatched": {"e_mail": {Type: TransformMask}},
+	}
+	for name, transforms := range samples {
+		if _, err := transformColumns(mapColumns(columns, ColumnSelection{}), transforms); err == nil {
+			t.Errorf("%s transform expected rejected", name)
+		}
+	}
+}
@@ -0,0 +1,845 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenSpace   tokenKind = iota
+	tokenComment           // -- line or /* block */ comment
+	tokenWord              // keyword, bare identifier or variable
+	tokenQuoted            // [identifier] or "identifier", Text holds the name
+	tokenString            // 'literal' or N'literal', Text holds the value
+	tokenNumber            // numeric or 0x binary literal
+	tokenSymbol            // operator or punctuation
+	tokenRaw               // MySQL text of a rewritten expression
+)
+
+// sqlToken - lexical token of a T-SQL text
+type sqlToken struct {
+	Kind tokenKind
+	Text string
+}
+
+// tokenizeSQL - tokens of the T-SQL text, the text is rebuilt by rendering every token
+func tokenizeSQL(sql string) []sqlToken {
+	runes := []rune(sql)
+	tokens := make([]sqlToken, 0)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		next := func(at int) rune {
+			if at < len(runes) {
+				return runes[at]
+			}
+			return 0
+		}
+		switch {
+		case unicode.IsSpace(r):
+			for i < len(runes) && unicode.IsSpace(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenSpace, string(runes[start:i])})
+		case r == '-' && next(i+1) == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenComment, string(runes[start:i])})
+		case r == '/' && next(i+1) == '*':
+			// block comments nest on SQL Server
+			depth := 0
+			for i < len(runes) {
+				if runes[i] == '/' && next(i+1) == '*' {
+					depth, i = depth+1, i+2
+				} else if runes[i] == '*' && next(i+1) == '/' {
+					depth, i = depth-1, i+2
+					if depth <= 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+			tokens = append(tokens, sqlToken{tokenComment, string(runes[start:i])})
+		case r == '[' || r == '"':
+			closing := ']'
+			if r == '"' {
+				closing = '"'
+			}
+			var sb strings.Builder
+			for i++; i < len(runes); i++ {
+				if runes[i] == closing {
+					if next(i+1) != closing {
+						i++
+						break
+					}
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			tokens = append(tokens, sqlToken{tokenQuoted, sb.String()})
+		case r == '\'' || ((r == 'N' || r == 'n') && next(i+1) == '\''):
+			if r != '\'' {
+				i++
+			}
+			var sb strings.Builder
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					if next(i+1) != '\'' {
+						i++
+						break
+					}
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			tokens = append(tokens, sqlToken{tokenString, sb.String()})
+		case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(next(i+1))):
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || unicode.IsLetter(runes[i]) || runes[i] == '.' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E') && !strings.HasPrefix(string(runes[start:i]), "0x"))) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenNumber, string(runes[start:i])})
+		case unicode.IsLetter(r) || r == '_' || r == '@' || r == '#':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_@#$", runes[i])) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenWord, string(runes[start:i])})
+		default:
+			i++
+			if op := string(runes[start : start+1]); strings.Contains("<>!", op) && strings.ContainsRune("=<>", next(i)) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{tokenSymbol, string(runes[start:i])})
+		}
+	}
+	return tokens
+}
+
+// mysql - MySQL text of the token
+func (t sqlToken) mysql() string {
+	switch t.Kind {
+	case tokenQuoted:
+		return quoteMySQL(t.Text)
+	case tokenString:
+		return quoteMySQLString(t.Text)
+	case tokenComment:
+		// MySQL takes a space after --
+		if strings.HasPrefix(t.Text, "--") && !strings.HasPrefix(t.Text, "-- ") {
+			return "-- " + t.Text[2:]
+		}
+	}
+	return t.Text
+}
+
+// quoteMySQLString - MySQL string literal of the value
+func quoteMySQLString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(value) + "'"
+}
+
+// is - whether the token is the word or the symbol, case-insensitively
+func (t sqlToken) is(texts ...string) bool {
+	if t.Kind != tokenWord && t.Kind != tokenSymbol {
+		return false
+	}
+	for _, text := range texts {
+		if strings.EqualFold(t.Text, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlNode - token, parenthesized group or function call of a T-SQL text
+type sqlNode struct {
+	Token sqlToken  // the token, ( of a group or the function name of a call
+	Group []sqlNode // contents of the parentheses of a group or a call
+	Paren bool      // whether the node is a group or a call
+	Str   bool      // whether the node yields a string
+}
+
+// isCall - whether the node is a function call
+func (n sqlNode) isCall(names ...string) bool {
+	return n.Paren && n.Token.Kind == tokenWord && (len(names) <= 0 || n.Token.is(names...))
+}
+
+// isBlank - whether the node is a space or a comment
+func (n sqlNode) isBlank() bool {
+	return !n.Paren && (n.Token.Kind == tokenSpace || n.Token.Kind == tokenComment)
+}
+
+// parseNodes - nodes of the tokens, parentheses grouped, up to the closing parenthesis
+func parseNodes(tokens []sqlToken, at *int) []sqlNode {
+	nodes := make([]sqlNode, 0)
+	for ; *at < len(tokens); *at++ {
+		t := tokens[*at]
+		if t.is(")") {
+			return nodes
+		} else if t.is("(") {
+			*at++
+			nodes = append(nodes, sqlNode{Token: t, Group: parseNodes(tokens, at), Paren: true})
+		} else {
+			nodes = append(nodes, sqlNode{Token: t, Str: t.Kind == tokenString})
+		}
+	}
+	return nodes
+}
+
+// renderNodes - MySQL text of the nodes
+func renderNodes(nodes []sqlNode) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		if n.isCall() {
+			sb.WriteString(n.Token.Text + "(" + renderNodes(n.Group) + ")")
+		} else if n.Paren {
+			sb.WriteString("(" + renderNodes(n.Group) + ")")
+		} else {
+			sb.WriteString(n.Token.mysql())
+		}
+	}
+	return sb.String()
+}
+
+// trimNodes - nodes without the leading and trailing spaces and comments
+func trimNodes(nodes []sqlNode) []sqlNode {
+	for 0 < len(nodes) && nodes[0].isBlank() {
+		nodes = nodes[1:]
+	}
+	for 0 < len(nodes) && nodes[len(nodes)-1].isBlank() {
+		nodes = nodes[:len(nodes)-1]
+	}
+	return nodes
+}
+
+// splitNodes - nodes split by the symbol on the level
+func splitNodes(nodes []sqlNode, symbol string) [][]sqlNode {
+	parts := [][]sqlNode{{}}
+	for _, n := range nodes {
+		if !n.Paren && n.Token.Kind == tokenSymbol && n.Token.Text == symbol {
+			parts = append(parts, []sqlNode{})
+			continue
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], n)
+	}
+	return parts
+}
+
+// renderArgs - trimmed MySQL texts of the call arguments
+func renderArgs(group []sqlNode) []string {
+	parts := splitNodes(group, ",")
+	args := make([]string, len(parts))
+	for i, p := range parts {
+		args[i] = renderNodes(trimNodes(p))
+	}
+	return args
+}
+
+// rawNode - node of the rewritten MySQL text
+func rawNode(text string, str bool) sqlNode {
+	return sqlNode{Token: sqlToken{tokenRaw, text}, Str: str}
+}
+
+var (
+	// sqlKeywords - words never taken as function names or concatenation operands
+	sqlKeywords = []string{
+		"ADD", "ALL", "AND", "ANY", "AS", "ASC", "BETWEEN", "BY", "CASE", "CROSS", "CREATE", "DESC", "DISTINCT",
+		"ELSE", "END", "EXCEPT", "EXISTS", "FROM", "FULL", "GROUP", "HAVING", "IN", "INNER", "INTERSECT", "IS",
+		"JOIN", "LIKE", "NOT", "NULL", "ON", "OR", "ORDER", "OUTER", "OVER", "PERCENT", "SELECT",
+		"SOME", "THEN", "TIES", "TOP", "UNION", "VALUES", "VIEW", "WHEN", "WHERE", "WITH",
+	}
+	// tableHints - hints of WITH (NOLOCK) clauses, no MySQL equivalent
+	tableHints = []string{
+		"NOLOCK", "READUNCOMMITTED", "READCOMMITTED", "REPEATABLEREAD", "SERIALIZABLE", "ROWLOCK", "PAGLOCK",
+		"TABLOCK", "TABLOCKX", "UPDLOCK", "XLOCK", "HOLDLOCK", "NOWAIT", "READPAST",
+	}
+	// stringFunctions - functions yielding strings, operands of + taken as concatenation
+	stringFunctions = []string{
+		"CONCAT", "LEFT", "RIGHT", "SUBSTRING", "UPPER", "LOWER", "LTRIM", "RTRIM", "TRIM", "REPLACE",
+		"REPLICATE", "REVERSE", "STUFF", "FORMAT", "DATE_FORMAT", "DATENAME", "CHAR", "NCHAR", "QUOTENAME",
+	}
+	// dateParts - MySQL units of the T-SQL date parts
+	dateParts = map[string]string{
+		"year": "YEAR", "yy": "YEAR", "yyyy": "YEAR",
+		"quarter": "QUARTER", "qq": "QUARTER", "q": "QUARTER",
+		"month": "MONTH", "mm": "MONTH", "m": "MONTH",
+		"dayofyear": "DAY", "dy": "DAY", "y": "DAY",
+		"day": "DAY", "dd": "DAY", "d": "DAY",
+		"week": "WEEK", "wk": "WEEK", "ww": "WEEK",
+		"weekday": "DAY", "dw": "DAY", "w": "DAY",
+		"hour": "HOUR", "hh": "HOUR",
+		"minute": "MINUTE", "mi": "MINUTE", "n": "MINUTE",
+		"second": "SECOND", "ss": "SECOND", "s": "SECOND",
+		"millisecond": "MICROSECOND", "ms": "MICROSECOND",
+		"microsecond": "MICROSECOND", "mcs": "MICROSECOND",
+	}
+	// dateDiffFormats - DATE_FORMAT formats truncating the DATEDIFF operands to the part
+	dateDiffFormats = map[string]string{
+		"hour": "%Y-%m-%d %H:00:00", "hh": "%Y-%m-%d %H:00:00",
+		"minute": "%Y-%m-%d %H:%i:00", "mi": "%Y-%m-%d %H:%i:00", "n": "%Y-%m-%d %H:%i:00",
+		"second": "%Y-%m-%d %H:%i:%s", "ss": "%Y-%m-%d %H:%i:%s", "s": "%Y-%m-%d %H:%i:%s",
+		"millisecond": "%Y-%m-%d %H:%i:%s.%f", "ms": "%Y-%m-%d %H:%i:%s.%f",
+	}
+	// convertStyles - DATE_FORMAT formats of the CONVERT date styles
+	convertStyles = map[string]string{
+		"23":  "%Y-%m-%d",
+		"101": "%m/%d/%Y",
+		"102": "%Y.%m.%d",
+		"103": "%d/%m/%Y",
+		"104": "%d.%m.%Y",
+		"108": "%H:%i:%s",
+		"111": "%Y/%m/%d",
+		"112": "%Y%m%d",
+		"120": "%Y-%m-%d %H:%i:%s",
+		"121": "%Y-%m-%d %H:%i:%s.%f",
+		"126": "%Y-%m-%dT%H:%i:%s.%f",
+	}
+)
+
+// castType - MySQL CAST type of the T-SQL type, and whether it is a string
+func castType(datatype string) (string, bool) {
+	datatype = strings.TrimSpace(datatype)
+	name, args := strings.ToLower(datatype), ""
+	if open := strings.Index(datatype, "("); 0 < open {
+		name = strings.ToLower(strings.TrimSpace(datatype[:open]))
+		args = strings.ReplaceAll(strings.Trim(datatype[open:], "() "), " ", "")
+	}
+	switch name {
+	case "char", "nchar", "varchar", "nvarchar", "text", "ntext", "sysname":
+		if len(args) <= 0 || strings.EqualFold(args, "max") {
+			return "CHAR", true
+		}
+		return "CHAR(" + args + ")", true
+	case "uniqueidentifier":
+		return "CHAR(36)", true
+	case "bigint", "int", "smallint", "tinyint":
+		return "SIGNED", false
+	case "bit":
+		return "UNSIGNED", false
+	case "decimal", "numeric":
+		if len(args) <= 0 {
+			return "DECIMAL(18,0)", false
+		}
+		return "DECIMAL(" + args + ")", false
+	case "money", "smallmoney":
+		return "DECIMAL(19,4)", false
+	case "float", "real":
+		return "DOUBLE", false
+	case "date":
+		return "DATE", false
+	case "datetime", "datetime2", "smalldatetime", "datetimeoffset":
+		return "DATETIME", false
+	case "time":
+		return "TIME", false
+	case "binary", "varbinary", "image":
+		return "BINARY", false
+	}
+	return strings.ToUpper(datatype), false
+}
+
+// charLength - length of the char type, empty when none or max
+func charLength(datatype string) string {
+	if open := strings.Index(datatype, "("); 0 < open {
+		if length := strings.Trim(datatype[open:], "() "); !strings.EqualFold(length, "max") {
+			return length
+		}
+	}
+	return ""
+}
+
+// rewriteCall - MySQL node of the T-SQL function call, the arguments already rewritten
+func rewriteCall(n sqlNode) sqlNode {
+	name := strings.ToUpper(n.Token.Text)
+	args := renderArgs(n.Group)
+	switch {
+	case name == "ISNULL" && len(args) == 2:
+		n.Token.Text = "IFNULL"
+	case name == "IIF" && len(args) == 3:
+		n.Token.Text = "IF"
+	case name == "GETDATE" && len(args) == 1 && len(args[0]) <= 0:
+		return rawNode("NOW()", false)
+	case name == "GETUTCDATE" && len(args) == 1 && len(args[0]) <= 0:
+		return rawNode("UTC_TIMESTAMP()", false)
+	case name == "SYSDATETIME" && len(args) == 1 && len(args[0]) <= 0:
+		return rawNode("NOW(6)", false)
+	case name == "LEN" && len(args) == 1:
+		// LEN leaves out the trailing spaces
+		return rawNode(fmt.Sprintf("CHAR_LENGTH(RTRIM(%s))", args[0]), false)
+	case name == "CAST":
+		// CAST(expr AS type)
+		for i := len(n.Group) - 1; 0 <= i; i-- {
+			if !n.Group[i].Paren && n.Group[i].Token.is("AS") {
+				target, str := castType(renderNodes(n.Group[i+1:]))
+				return rawNode(fmt.Sprintf("CAST(%s AS %s)", renderNodes(trimNodes(n.Group[:i])), target), str)
+			}
+		}
+	case name == "CONVERT" && (len(args) == 2 || len(args) == 3):
+		return rewriteConvert(args)
+	case (name == "DATEADD" || name == "DATEDIFF") && len(args) == 3:
+		unit, known := dateParts[strings.ToLower(strings.Trim(args[0], "`'"))]
+		if !known {
+			break
+		}
+		if name == "DATEADD" {
+			if strings.EqualFold(args[0], "millisecond") || strings.EqualFold(args[0], "ms") {
+				args[1] = fmt.Sprintf("(%s)*1000", args[1])
+			}
+			return rawNode(fmt.Sprintf("TIMESTAMPADD(%s, %s, %s)", unit, args[1], args[2]), false)
+		}
+		// DATEDIFF counts the boundaries crossed, TIMESTAMPDIFF the complete intervals:
+		// the operands are truncated to the unit first
+		switch unit {
+		case "YEAR":
+			return rawNode(fmt.Sprintf("(YEAR(%[2]s) - YEAR(%[1]s))", args[1], args[2]), false)
+		case "QUARTER":
+			return rawNode(fmt.Sprintf("((YEAR(%[2]s) - YEAR(%[1]s)) * 4 + QUARTER(%[2]s) - QUARTER(%[1]s))", args[1], args[2]), false)
+		case "MONTH":
+			return rawNode(fmt.Sprintf("((YEAR(%[2]s) - YEAR(%[1]s)) * 12 + MONTH(%[2]s) - MONTH(%[1]s))", args[1], args[2]), false)
+		case "WEEK":
+			// weeks start on Sunday whatever DATEFIRST is
+			return rawNode(fmt.Sprintf("(((TO_DAYS(%[2]s) - DAYOFWEEK(%[2]s)) - (TO_DAYS(%[1]s) - DAYOFWEEK(%[1]s))) DIV 7)", args[1], args[2]), false)
+		case "DAY":
+			return rawNode(fmt.Sprintf("DATEDIFF(%s, %s)", args[2], args[1]), false)
+		}
+		if format, exists := dateDiffFormats[strings.ToLower(strings.Trim(args[0], "`'"))]; exists {
+			truncate := func(arg string) string {
+				return fmt.Sprintf("DATE_FORMAT(%s, %s)", arg, quoteMySQLString(format))
+			}
+			if unit == "MICROSECOND" {
+				// milliseconds, the microseconds cut to 3 digits
+				truncate = func(arg string) string {
+					return fmt.Sprintf("LEFT(DATE_FORMAT(%s, %s), 23)", arg, quoteMySQLString(format))
+				}
+				return rawNode(fmt.Sprintf("(TIMESTAMPDIFF(MICROSECOND, %s, %s) DIV 1000)", truncate(args[1]), truncate(args[2])), false)
+			}
+			return rawNode(fmt.Sprintf("TIMESTAMPDIFF(%s, %s, %s)", unit, truncate(args[1]), truncate(args[2])), false)
+		}
+		return rawNode(fmt.Sprintf("TIMESTAMPDIFF(%s, %s, %s)", unit, args[1], args[2]), false)
+	}
+	n.Str = n.Token.is(stringFunctions...)
+	return n
+}
+
+// rewriteConvert - MySQL expression of CONVERT(type, expr[, style]),
+// date styles are formatted or parsed by the style format
+func rewriteConvert(args []string) sqlNode {
+	target, str := castType(args[0])
+	if len(args) == 3 {
+		if format, known := convertStyles[strings.TrimSpace(args[2])]; known {
+			if str {
+				formatted := fmt.Sprintf("DATE_FORMAT(%s, %s)", args[1], quoteMySQLString(format))
+				if length := charLength(args[0]); 0 < len(length) {
+					formatted = fmt.Sprintf("LEFT(%s, %s)", formatted, length)
+				}
+				return rawNode(formatted, true)
+			}
+			if target == "DATE" || target == "DATETIME" || target == "TIME" {
+				return rawNode(fmt.Sprintf("STR_TO_DATE(%s, %s)", args[1], quoteMySQLString(format)), false)
+			}
+		}
+	}
+	return rawNode(fmt.Sprintf("CAST(%s AS %s)", args[1], target), str)
+}
+
+// isHintGroup - whether the group holds table hints only, (NOLOCK)
+func isHintGroup(n sqlNode) bool {
+	if !n.Paren || n.isCall() {
+		return false
+	}
+	found := false
+	for _, c := range n.Group {
+		if c.isBlank() || c.Token.is(",") {
+			continue
+		} else if c.Paren || !c.Token.is(tableHints...) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// rewriteNodes - MySQL nodes of the T-SQL nodes on a level
+func rewriteNodes(nodes []sqlNode) []sqlNode {
+	// groups first
+	for i, n := range nodes {
+		if n.Paren {
+			nodes[i].Group = rewriteNodes(n.Group)
+			if inner := trimNodes(nodes[i].Group); len(inner) == 1 && inner[0].Str {
+				nodes[i].Str = true
+			}
+		}
+	}
+
+	rets := make([]sqlNode, 0, len(nodes))
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		// dbo. prefixes
+		if !n.Paren && (n.Token.Kind == tokenWord || n.Token.Kind == tokenQuoted) && strings.EqualFold(n.Token.Text, "dbo") &&
+			i+1 < len(nodes) && nodes[i+1].Token.is(".") && !nodes[i+1].Paren {
+			i++
+			continue
+		}
+		// table hints
+		if isHintGroup(n) {
+			for 0 < len(rets) && rets[len(rets)-1].isBlank() {
+				rets = rets[:len(rets)-1]
+			}
+			if 0 < len(rets) && rets[len(rets)-1].Token.is("WITH") {
+				rets = rets[:len(rets)-1]
+				for 0 < len(rets) && rets[len(rets)-1].isBlank() {
+					rets = rets[:len(rets)-1]
+				}
+			}
+			continue
+		}
+		// function calls, spaces between the name and the parenthesis are not allowed on MySQL
+		if n.Token.Kind == tokenWord && !n.Paren && !n.Token.is(sqlKeywords...) {
+			j := i + 1
+			for j < len(nodes) && nodes[j].isBlank() && nodes[j].Token.Kind == tokenSpace {
+				j++
+			}
+			if j < len(nodes) && nodes[j].Paren && !nodes[j].isCall() && !isHintGroup(nodes[j]) {
+				call := sqlNode{Token: n.Token, Group: nodes[j].Group, Paren: true}
+				rets = append(rets, rewriteCall(call))
+				i = j
+				continue
+			}
+		}
+		// CREATE VIEW in upper case
+		if n.Token.is("VIEW") || (n.Token.is("CREATE") && !n.Paren) {
+			n.Token.Text = strings.ToUpper(n.Token.Text)
+		}
+		rets = append(rets, n)
+	}
+	rets = rewriteTop(rets)
+	return rewriteConcat(rets)
+}
+
+// nextNode - index of the next node not blank from the index, len(nodes) when none
+func nextNode(nodes []sqlNode, from int) int {
+	for from < len(nodes) && nodes[from].isBlank() {
+		from++
+	}
+	return from
+}
+
+// rewriteTop - SELECT TOP n as LIMIT n at the end of the select, TOP 100 PERCENT dropped.
+// a select followed by UNION is parenthesized, the limit applies to the select only
+func rewriteTop(nodes []sqlNode) []sqlNode {
+	for i := 0; i < len(nodes); i++ {
+		if nodes[i].Paren || !nodes[i].Token.is("SELECT") {
+			continue
+		}
+		top := nextNode(nodes, i+1)
+		if top < len(nodes) && nodes[top].Token.is("DISTINCT", "ALL") {
+			top = nextNode(nodes, top+1)
+		}
+		if len(nodes) <= top || nodes[top].Paren || !nodes[top].Token.is("TOP") {
+			continue
+		}
+		count := nextNode(nodes, top+1)
+		if len(nodes) <= count {
+			continue
+		}
+		limit := renderNodes(trimNodes([]sqlNode{nodes[count]}))
+		if nodes[count].Paren {
+			limit = renderNodes(trimNodes(nodes[count].Group))
+		}
+		end := nextNode(nodes, count+1)
+		percent := end < len(nodes) && nodes[end].Token.is("PERCENT")
+		if percent {
+			if limit != "100" {
+				fmt.Printf("TOP %s PERCENT is not translated\n", limit)
+				continue
+			}
+			end = nextNode(nodes, end+1)
+		}
+		// remove TOP n, then place the limit before UNION or the end of the level
+		nodes = append(nodes[:top], nodes[end:]...)
+		if percent {
+			continue
+		}
+		at := len(nodes)
+		for j := top; j < len(nodes); j++ {
+			if !nodes[j].Paren && nodes[j].Token.is("UNION", "EXCEPT", "INTERSECT") {
+				at = j
+				break
+			}
+		}
+		union := at < len(nodes)
+		for top < at && (nodes[at-1].isBlank() || nodes[at-1].Token.is(";")) {
+			at--
+		}
+		limited := []sqlNode{rawNode(" LIMIT "+limit, false)}
+		if union {
+			limited = append(limited, rawNode(")", false))
+		}
+		nodes = append(nodes[:at], append(limited, nodes[at:]...)...)
+		if union {
+			nodes = append(nodes[:i], append([]sqlNode{rawNode("(", false)}, nodes[i:]...)...)
+			i++
+		}
+	}
+	return nodes
+}
+
+// isOperand - whether the part is a single operand of +, a value or a qualified column
+func isOperand(part []sqlNode) bool {
+	part = trimNodes(part)
+	if len(part) == 1 && (part[0].Paren || part[0].Token.Kind == tokenRaw ||
+		part[0].Token.Kind == tokenString || part[0].Token.Kind == tokenNumber) {
+		return true
+	}
+	for i, n := range part {
+		if i%2 == 1 && !n.Token.is(".") {
+			return false
+		} else if i%2 == 0 && (n.Paren || (n.Token.Kind != tokenQuoted && (n.Token.Kind != tokenWord || n.Token.is(sqlKeywords...)))) {
+			return false
+		}
+	}
+	return len(part)%2 == 1
+}
+
+// isDelimiter - whether the node ends an expression on a level
+func isDelimiter(n sqlNode) bool {
+	if n.Paren {
+		return false
+	}
+	switch n.Token.Kind {
+	case tokenSymbol:
+		// arithmetic operators stay in the expression, keeping it from CONCAT
+		return !n.Token.is("+", "-", "*", "/", "%", ".")
+	case tokenWord:
+		return n.Token.is(sqlKeywords...)
+	}
+	return false
+}
+
+// rewriteConcat - + chains holding a string operand as CONCAT
+func rewriteConcat(nodes []sqlNode) []sqlNode {
+	rets := make([]sqlNode, 0, len(nodes))
+	start := 0
+	flush := func(end int) {
+		segment := nodes[start:end]
+		parts := splitNodes(segment, "+")
+		concat := 1 < len(parts)
+		str := false
+		for _, p := range parts {
+			concat = concat && isOperand(p)
+			str = str || (concat && trimNodes(p)[0].Str)
+		}
+		if !concat || !str {
+			rets = append(rets, segment...)
+			return
+		}
+		// keep the spaces around the expression
+		lead, trail := 0, len(segment)
+		for lead < trail && segment[lead].isBlank() {
+			lead++
+		}
+		for lead < trail && segment[trail-1].isBlank() {
+			trail--
+		}
+		operands := make([]string, len(parts))
+		for i, p := range parts {
+			operands[i] = renderNodes(trimNodes(p))
+		}
+		rets = append(rets, segment[:lead]...)
+		rets = append(rets, rawNode("CONCAT("+strings.Join(operands, ", ")+")", true))
+		rets = append(rets, segment[trail:]...)
+	}
+	for i, n := range nodes {
+		if isDelimiter(n) {
+			flush(i)
+			rets = append(rets, n)
+			start = i + 1
+		}
+	}
+	flush(len(nodes))
+	return rets
+}
+
+// ObjectNames - target names of the objects views select from, keys in lower case
+type ObjectNames struct {
+	Schema    string            // source database of the views
+	Views     map[string]string // target names of the views created, by the view(key)
+	Databases map[string]string // target databases, by the replicated source database(key)
+	Tables    map[string]string // target names of the tables configured by name, by database.owner.table(key)
+}
+
+// target - target name of the object of the source database, kept when not mapped.
+// tables are looked up by the owner, dbo when empty
+func (on ObjectNames) target(database string, owner string, name string) string {
+	if strings.EqualFold(database, on.Schema) {
+		if target, exists := on.Views[strings.ToLower(name)]; exists {
+			return target
+		}
+	}
+	if len(owner) <= 0 {
+		owner = DefaultSourceSchema
+	}
+	if target, exists := on.Tables[strings.ToLower(database+"."+owner+"."+name)]; exists {
+		return target
+	}
+	return name
+}
+
+// renameObjects - tokens with the objects created and selected from renamed to the target names.
+// owners are dropped, owner.name is resolved as the table of the owner.
+// names of other databases, database.owner.name or server.database.owner.name, are resolved
+// through the replicated databases and kept as database.name otherwise.
+// every object of a FROM list is renamed, FROM a, b as well as the joined ones
+func renameObjects(tokens []sqlToken, names ObjectNames) []sqlToken {
+	next := func(from int) int {
+		for from < len(tokens) && (tokens[from].Kind == tokenSpace || tokens[from].Kind == tokenComment) {
+			from++
+		}
+		return from
+	}
+	text := func(part int) string {
+		if part < 0 {
+			return ""
+		}
+		return tokens[part].Text
+	}
+	// whether a FROM list is open, by the parenthesis depth
+	lists := []bool{false}
+	rets := make([]sqlToken, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		rets = append(rets, tokens[i])
+		depth := len(lists) - 1
+		switch {
+		case tokens[i].is("("):
+			lists = append(lists, false)
+			continue
+		case tokens[i].is(")"):
+			if 0 < depth {
+				lists = lists[:depth]
+			}
+			continue
+		case tokens[i].is("FROM", "JOIN"):
+			lists[depth] = true
+		case tokens[i].is(","):
+			if !lists[depth] {
+				continue
+			}
+		case tokens[i].is("VIEW"):
+		default:
+			if tokens[i].is("WHERE", "GROUP", "HAVING", "ORDER", "UNION", "EXCEPT", "INTERSECT", "SELECT", "OPTION", "FOR") {
+				lists[depth] = false
+			}
+			continue
+		}
+		// name parts of the object, -1 for the empty owner of database..name
+		parts := []int{}
+		for j := next(i + 1); j < len(tokens) && (tokens[j].Kind == tokenWord || tokens[j].Kind == tokenQuoted); {
+			parts = append(parts, j)
+			dot := next(j + 1)
+			if len(tokens) <= dot || !tokens[dot].is(".") {
+				break
+			}
+			for j = next(dot + 1); j < len(tokens) && tokens[j].is("."); j = next(j + 1) {
+				parts = append(parts, -1)
+			}
+		}
+		empty := false
+		for k, part := range parts {
+			// only the owner is left out
+			empty = empty || (part < 0 && k != len(parts)-2)
+		}
+		if len(parts) <= 0 || 4 < len(parts) || empty {
+			continue
+		}
+		last := parts[len(parts)-1]
+		name := tokens[last].Text
+		owner := ""
+		if 2 <= len(parts) {
+			owner = text(parts[len(parts)-2])
+		}
+		if len(parts) <= 2 {
+			// owner.name of the database, a MySQL name has no owner
+			target := sqlToken{tokenQuoted, names.target(names.Schema, owner, name)}
+			if target.Text == name {
+				target = tokens[last]
+			}
+			rets = append(rets, tokens[i+1:parts[0]]...)
+			rets = append(rets, target)
+			i = last
+			continue
+		}
+		database := text(parts[len(parts)-3])
+		qualified := []sqlToken{{tokenQuoted, database}, {tokenSymbol, "."}, {tokenQuoted, names.target(database, owner, name)}}
+		if target, exists := names.Databases[strings.ToLower(database)]; exists {
+			qualified[0].Text = target
+		}
+		rets = append(rets, tokens[i+1:parts[0]]...)
+		rets = append(rets, qualified...)
+		i = last
+	}
+	return rets
+}
+
+// translateView - MySQL text of the T-SQL view on the target names of the objects
+func translateView(def string, names ObjectNames) string {
+	return translateTokens(renameObjects(tokenizeSQL(def), names))
+}
+
+// translateTSQL - MySQL text of the T-SQL view or expression
+func translateTSQL(sql string) string {
+	return translateTokens(tokenizeSQL(sql))
+}
+
+// translateTokens - MySQL text of the T-SQL tokens
+func translateTokens(tokens []sqlToken) string {
+	return renderNodes(rewriteNodes(parseTokens(tokens)))
+}
+
+// parseTokens - nodes of the T-SQL tokens
+func parseTokens(tokens []sqlToken) []sqlNode {
+	nodes := make([]sqlNode, 0)
+	for at := 0; at < len(tokens); at++ {
+		nodes = append(nodes, parseNodes(tokens, &at)...)
+		if at < len(tokens) {
+			// unbalanced closing parenthesis
+			nodes = append(nodes, sqlNode{Token: tokens[at]})
+		}
+	}
+	return nodes
+}
+
+// untypedSums - + chains between columns only in the T-SQL text, kept as additions on MySQL.
+// T-SQL concatenates string columns, the column types are not known to the translation
+func untypedSums(sql string) []string {
+	return columnSums(rewriteNodes(parseTokens(tokenizeSQL(sql))))
+}
+
+// columnSums - + chains of the nodes with columns for every operand
+func columnSums(nodes []sqlNode) []string {
+	sums := make([]string, 0)
+	start := 0
+	flush := func(end int) {
+		parts := splitNodes(nodes[start:end], "+")
+		columns := 1 < len(parts)
+		for _, p := range parts {
+			p = trimNodes(p)
+			columns = columns && isOperand(p) && !p[0].Paren && (p[0].Token.Kind == tokenWord || p[0].Token.Kind == tokenQuoted)
+		}
+		if columns {
+			sums = append(sums, renderNodes(trimNodes(nodes[start:end])))
+		}
+	}
+	for i, n := range nodes {
+		if n.Paren {
+			sums = append(sums, columnSums(n.Group)...)
+		}
+		if isDelimiter(n) {
+			flush(i)
+			start = i + 1
+		}
+	}
+	flush(len(nodes))
+	return sums
+}
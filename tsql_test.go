@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenizeSQL(t *testing.T) {
+	sql := "SELECT [a]]b], N'it''s [x]', 1.5e-3, 0x1F /* c /* n */ */ -- tail\nFROM \"t\" WHERE a<>b"
+	expects := []sqlToken{
+		{tokenWord, "SELECT"}, {tokenSpace, " "}, {tokenQuoted, "a]b"}, {tokenSymbol, ","}, {tokenSpace, " "},
+		{tokenString, "it's [x]"}, {tokenSymbol, ","}, {tokenSpace, " "},
+		{tokenNumber, "1.5e-3"}, {tokenSymbol, ","}, {tokenSpace, " "},
+		{tokenNumber, "0x1F"}, {tokenSpace, " "}, {tokenComment, "/* c /* n */ */"}, {tokenSpace, " "},
+		{tokenComment, "-- tail"}, {tokenSpace, "\n"},
+		{tokenWord, "FROM"}, {tokenSpace, " "}, {tokenQuoted, "t"}, {tokenSpace, " "},
+		{tokenWord, "WHERE"}, {tokenSpace, " "}, {tokenWord, "a"}, {tokenSymbol, "<>"}, {tokenWord, "b"},
+	}
+	tokens := tokenizeSQL(sql)
+	if len(tokens) != len(expects) {
+		t.Fatalf("expected %d tokens but %v", len(expects), tokens)
+	}
+	for i, e := range expects {
+		if tokens[i] != e {
+			t.Errorf("[%d] expected %v but %v", i, e, tokens[i])
+		}
+	}
+}
+
+func TestTranslateTSQL(t *testing.T) {
+	samples := []PatternSample{
+		// literals and comments are kept
+		{"SELECT 'C:\\temp [x] 25%' AS [p], N'김' --note", "SELECT 'C:\\\\temp [x] 25%' AS `p`, '김' -- note"},
+		{"SELECT a FROM [CheilOptimizer_DM].dbo.Cleansed_Dataset", "SELECT a FROM `CheilOptimizer_DM`.Cleansed_Dataset"},
+		// TOP
+		{"SELECT TOP 10 a FROM t ORDER BY a", "SELECT a FROM t ORDER BY a LIMIT 10"},
+		{"SELECT DISTINCT TOP (5) a FROM t;", "SELECT DISTINCT a FROM t LIMIT 5;"},
+		{"SELECT TOP 100 PERCENT a FROM t ORDER BY a", "SELECT a FROM t ORDER BY a"},
+		{"SELECT a FROM (SELECT TOP 1 a FROM t ORDER BY b DESC) x", "SELECT a FROM (SELECT a FROM t ORDER BY b DESC LIMIT 1) x"},
+		{"SELECT TOP 3 a FROM t ORDER BY a UNION ALL SELECT TOP 2 a FROM u", "(SELECT a FROM t ORDER BY a LIMIT 3) UNION ALL SELECT a FROM u LIMIT 2"},
+		// functions
+		{"SELECT ISNULL([a], 0), GETDATE(), GETUTCDATE (), LEN(b)", "SELECT IFNULL(`a`, 0), NOW(), UTC_TIMESTAMP(), CHAR_LENGTH(RTRIM(b))"},
+		{"SELECT IIF(a > 0, 'y', 'n')", "SELECT IF(a > 0, 'y', 'n')"},
+		{"SELECT CAST([a] AS nvarchar(50)), CAST(b AS int), CAST(c AS decimal(18, 2))", "SELECT CAST(`a` AS CHAR(50)), CAST(b AS SIGNED), CAST(c AS DECIMAL(18,2))"},
+		{"SELECT CONVERT(varchar(10), [Date], 120)", "SELECT LEFT(DATE_FORMAT(`Date`, '%Y-%m-%d %H:%i:%s'), 10)"},
+		{"SELECT CONVERT(datetime, s, 112), CONVERT(int, n)", "SELECT STR_TO_DATE(s, '%Y%m%d'), CAST(n AS SIGNED)"},
+		{"SELECT DATEADD(day, -7, GETDATE())", "SELECT TIMESTAMPADD(DAY, -7, NOW())"},
+		{"SELECT DATEDIFF(dd, a, b), DATEDIFF(month, a, b), DATEDIFF(hour, a, b)",
+			"SELECT DATEDIFF(b, a), ((YEAR(b) - YEAR(a)) * 12 + MONTH(b) - MONTH(a)), TIMESTAMPDIFF(HOUR, DATE_FORMAT(a, '%Y-%m-%d %H:00:00'), DATE_FORMAT(b, '%Y-%m-%d %H:00:00'))"},
+		// boundaries crossed, 10:59 to 11:01 is an hour
+		{"SELECT DATEDIFF(hour, '2021-05-10 10:59', '2021-05-10 11:01')",
+			"SELECT TIMESTAMPDIFF(HOUR, DATE_FORMAT('2021-05-10 10:59', '%Y-%m-%d %H:00:00'), DATE_FORMAT('2021-05-10 11:01', '%Y-%m-%d %H:00:00'))"},
+		{"SELECT DATEDIFF(mi, a, b), DATEDIFF(second, a, b), DATEDIFF(ms, a, b), DATEDIFF(mcs, a, b)",
+			"SELECT TIMESTAMPDIFF(MINUTE, DATE_FORMAT(a, '%Y-%m-%d %H:%i:00'), DATE_FORMAT(b, '%Y-%m-%d %H:%i:00')), " +
+				"TIMESTAMPDIFF(SECOND, DATE_FORMAT(a, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(b, '%Y-%m-%d %H:%i:%s')), " +
+				"(TIMESTAMPDIFF(MICROSECOND, LEFT(DATE_FORMAT(a, '%Y-%m-%d %H:%i:%s.%f'), 23), LEFT(DATE_FORMAT(b, '%Y-%m-%d %H:%i:%s.%f'), 23)) DIV 1000), " +
+				"TIMESTAMPDIFF(MICROSECOND, a, b)"},
+		{"SELECT DATEDIFF(week, a, b), DATEDIFF(qq, a, b)",
+			"SELECT (((TO_DAYS(b) - DAYOFWEEK(b)) - (TO_DAYS(a) - DAYOFWEEK(a))) DIV 7), ((YEAR(b) - YEAR(a)) * 4 + QUARTER(b) - QUARTER(a))"},
+		// string concatenation
+		{"SELECT [First] + ' ' + [Last] AS name, a + 1 AS n", "SELECT CONCAT(`First`, ' ', `Last`) AS name, a + 1 AS n"},
+		{"SELECT t.a + '-' + CONVERT(varchar, t.b) FROM t", "SELECT CONCAT(t.a, '-', CAST(t.b AS CHAR)) FROM t"},
+		{"WHERE code = 'A' + b AND n = a + b", "WHERE code = CONCAT('A', b) AND n = a + b"},
+		// table hints
+		{"SELECT a FROM t WITH (NOLOCK) JOIN u (nolock) ON t.a = u.a", "SELECT a FROM t JOIN u ON t.a = u.a"},
+	}
+	for i, s := range samples {
+		if sql := translateTSQL(s.Sample); sql != s.Expect {
+			t.Errorf("[%d] expected %s but %s", i, s.Expect, sql)
+		}
+	}
+}
+
+func TestUntypedSums(t *testing.T) {
+	sql := "SELECT [First] + [Last] AS name, [First] + ' ' + [Last] AS full_name, a + 1 AS n, ISNULL(t.x + t.y, 0) FROM t WHERE n = a + b"
+	sums := untypedSums(sql)
+	if strings.Join(sums, "|") != "`First` + `Last`|t.x + t.y|a + b" {
+		t.Errorf("unexpected sums %v", sums)
+	}
+	if sums := untypedSums("SELECT a, b FROM t"); len(sums) != 0 {
+		t.Errorf("unexpected sums %v", sums)
+	}
+}
+
+func TestTranslateView(t *testing.T) {
+	names := ObjectNames{
+		Schema:    "Sales",
+		Views:     map[string]string{"v_sales": "sales_v_sales", "v_base": "sales_v_base"},
+		Databases: map[string]string{"cheiloptimizer_dm": "dm", "sales": "sales_replica"},
+		Tables: map[string]string{"cheiloptimizer_dm.dbo.cleansed_dataset": "legacy_cleansed", "sales.dbo.orders": "legacy_orders",
+			"sales.archive.orders": "legacy_archive_orders"},
+	}
+	samples := []PatternSample{
+		{"CREATE VIEW [dbo].[V_Sales] AS SELECT a FROM dbo.v_base b JOIN [V_Sales] s ON b.a = s.a", "CREATE VIEW `sales_v_sales` AS SELECT a FROM `sales_v_base` b JOIN `sales_v_sales` s ON b.a = s.a"},
+		// tables and columns
+		{"CREATE VIEW v_other AS SELECT v_base FROM t JOIN Orders o ON t.a = o.a", "CREATE VIEW v_other AS SELECT v_base FROM t JOIN `legacy_orders` o ON t.a = o.a"},
+		// other databases, through the database mapping
+		{"SELECT a FROM [CheilOptimizer_DM].dbo.Cleansed_Dataset", "SELECT a FROM `dm`.`legacy_cleansed`"},
+		{"SELECT a FROM [srv].[CheilOptimizer_DM].[dbo].[Other] x JOIN Sales.dbo.V_Base y ON x.a = y.a", "SELECT a FROM `dm`.`Other` x JOIN `sales_replica`.`sales_v_base` y ON x.a = y.a"},
+		{"SELECT a FROM Archive.dbo.v_base", "SELECT a FROM `Archive`.`v_base`"},
+		// owners of the database
+		{"SELECT a FROM archive.orders o JOIN dbo.orders p ON o.a = p.a", "SELECT a FROM `legacy_archive_orders` o JOIN `legacy_orders` p ON o.a = p.a"},
+		{"SELECT a FROM [reports].[Summary]", "SELECT a FROM `Summary`"},
+		// comma joins and the default owner
+		{"SELECT a FROM Orders o, dbo.v_base b WITH (NOLOCK), [CheilOptimizer_DM]..Cleansed_Dataset c WHERE o.a = b.a", "SELECT a FROM `legacy_orders` o, `sales_v_base` b, `dm`.`legacy_cleansed` c WHERE o.a = b.a"},
+		{"SELECT a, b FROM (SELECT a, b FROM t, orders) x, v_base ORDER BY a, b", "SELECT a, b FROM (SELECT a, b FROM t, `legacy_orders`) x, `sales_v_base` ORDER BY a, b"},
+		{"SELECT a FROM Sales..Orders", "SELECT a FROM `sales_replica`.`legacy_orders`"},
+	}
+	for i, s := range samples {
+		if got := translateView(s.Sample, names); got != s.Expect {
+			t.Errorf("[%d] expected %s but %s", i, s.Expect, got)
+		}
+	}
+	if got := translateView("CREATE VIEW v_base AS SELECT 1", ObjectNames{}); got != "CREATE VIEW v_base AS SELECT 1" {
+		t.Errorf("unexpected %s", got)
+	}
+}
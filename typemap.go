@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	mysqlVarcharLimit = 400        // longer strings become text, keeping wide rows within the 65,535 bytes limit
+	mysqlCharLimit    = 255        // longest char/binary column
+	mysqlTimeScale    = 6          // fractional seconds digits of time/datetime
+	mssqlMaxLength    = 1073741823 // sp_columns precision of (n)varchar(max), varbinary(max) reports more
+)
+
+// mssqlType - source column type as reported by sp_columns.
+// Precision is the length in characters for string types, in bytes for binary types
+type mssqlType struct {
+	Name      string
+	Precision int64
+	Scale     int64
+}
+
+// isMax - whether the type is declared (max)
+func (t mssqlType) isMax() bool {
+	return t.Precision <= 0 || mssqlMaxLength <= t.Precision
+}
+
+// String - type as declared on SQL Server, e.g. nvarchar(50), decimal(18,2), varchar(max)
+func (t mssqlType) String() string {
+	switch t.Name {
+	case "decimal", "numeric":
+		return fmt.Sprintf("%s(%d,%d)", t.Name, t.Precision, t.Scale)
+	case "char", "nchar", "varchar", "nvarchar", "binary", "varbinary":
+		if t.isMax() {
+			return t.Name + "(max)"
+		}
+		return fmt.Sprintf("%s(%d)", t.Name, t.Precision)
+	case "time", "datetime2", "datetimeoffset":
+		return fmt.Sprintf("%s(%d)", t.Name, t.Scale)
+	}
+	return t.Name
+}
+
+// mysqlTypeBuilder - builds a MySQL type for the source type
+type mysqlTypeBuilder func(t mssqlType) string
+
+func fixedType(name string) mysqlTypeBuilder {
+	return func(t mssqlType) string {
+		return name
+	}
+}
+
+func decimalType(t mssqlType) string {
+	return fmt.Sprintf("decimal(%d,%d)", t.Precision, t.Scale)
+}
+
+func floatType(t mssqlType) string {
+	// float(1..24) is single precision
+	if 0 < t.Precision && t.Precision <= 24 {
+		return "float"
+	}
+	return "double"
+}
+
+func fractionalType(name string) mysqlTypeBuilder {
+	return func(t mssqlType) string {
+		scale := t.Scale
+		if mysqlTimeScale < scale {
+			scale = mysqlTimeScale
+		}
+		if scale <= 0 {
+			return name
+		}
+		return fmt.Sprintf("%s(%d)", name, scale)
+	}
+}
+
+func charType(t mssqlType) string {
+	if t.Precision <= mysqlCharLimit {
+		return fmt.Sprintf("char(%d)", t.Precision)
+	}
+	return varcharType(t)
+}
+
+func varcharType(t mssqlType) string {
+	if t.isMax() {
+		return "longtext"
+	} else if mysqlVarcharLimit < t.Precision {
+		return "text"
+	}
+	return fmt.Sprintf("varchar(%d)", t.Precision)
+}
+
+func binaryType(t mssqlType) string {
+	if t.Precision <= mysqlCharLimit {
+		return fmt.Sprintf("binary(%d)", t.Precision)
+	}
+	return varbinaryType(t)
+}
+
+func varbinaryType(t mssqlType) string {
+	if t.isMax() {
+		return "longblob"
+	} else if mysqlVarcharLimit < t.Precision {
+		return "blob"
+	}
+	return fmt.Sprintf("varbinary(%d)", t.Precision)
+}
+
+// mssqlTypeMap - SQL Server type(key) to MySQL type
+var mssqlTypeMap = map[string]mysqlTypeBuilder{
+	// exact numerics
+	"bigint":     fixedType("bigint"),
+	"int":        fixedType("int"),
+	"smallint":   fixedType("smallint"),
+	"tinyint":    fixedType("tinyint unsigned"),
+	"bit":        fixedType("tinyint(1)"),
+	"decimal":    decimalType,
+	"numeric":    decimalType,
+	"money":      fixedType("decimal(19,4)"),
+	"smallmoney": fixedType("decimal(10,4)"),
+	// approximate numerics
+	"float": floatType,
+	"real":  fixedType("float"),
+	// date and time
+	"date":           fixedType("date"),
+	"time":           fractionalType("time"),
+	"datetime":       fixedType("datetime(3)"),
+	"datetime2":      fractionalType("datetime"),
+	"smalldatetime":  fixedType("datetime"),
+	"datetimeoffset": fractionalType("datetime"),
+	// strings
+	"char":     charType,
+	"nchar":    charType,
+	"varchar":  varcharType,
+	"nvarchar": varcharType,
+	"text":     fixedType("longtext"),
+	"ntext":    fixedType("longtext"),
+	"sysname":  fixedType("varchar(128)"),
+	// binaries
+	"binary":     binaryType,
+	"varbinary":  varbinaryType,
+	"image":      fixedType("longblob"),
+	"timestamp":  fixedType("binary(8)"),
+	"rowversion": fixedType("binary(8)"),
+	// others
+	"uniqueidentifier": fixedType("char(36)"),
+	"xml":              fixedType("longtext"),
+	"sql_variant":      fixedType("text"),
+	"hierarchyid":      fixedType("varbinary(892)"),
+	"geography":        fixedType("longblob"),
+	"geometry":         fixedType("longblob"),
+}
+
+// mapMSSQLType - MySQL type of the source type, longtext for unknown types
+func mapMSSQLType(t mssqlType) string {
+	if build, exists := mssqlTypeMap[t.Name]; exists {
+		return build(t)
+	}
+	fmt.Printf("unknown type %s, mapped to longtext\n", t.Name)
+	return "longtext"
+}
+
+// Match - whether the override applies to the source column
+func (o TypeOverride) Match(col ColumnDefinition) bool {
+	if 0 < len(o.SourceType) && !matchPattern(o.SourceType, col.SourceType) {
+		return false
+	}
+	if 0 < len(o.Column) && !matchPattern(o.Column, col.Name) {
+		return false
+	}
+	return 0 < len(o.Type)
+}
+
+// overrideType - overridden target type of the source column, false when none applies.
+// column types precede the overrides, the first matching override wins
+func overrideType(col ColumnDefinition, types map[string]string, overrides []TypeOverride) (string, bool) {
+	for name, datatype := range types {
+		if strings.EqualFold(name, col.Name) {
+			return datatype, true
+		}
+	}
+	for _, o := range overrides {
+		if o.Match(col) {
+			return o.Type, true
+		}
+	}
+	return "", false
+}
+
+// overrideTypes - apply the type overrides to the target columns
+func overrideTypes(mappings []ColumnMapping, types map[string]string, overrides []TypeOverride) []ColumnMapping {
+	for i, m := range mappings {
+		if datatype, overridden := overrideType(m.Source, types, overrides); overridden {
+			mappings[i].Target.DataType = datatype
+			mappings[i].Overridden = true
+		}
+	}
+	return mappings
+}
+
+const (
+	ComputedGenerated   = "generated"   // computed columns become generated columns
+	ComputedMaterialize = "materialize" // computed columns become plain columns holding the copied values
+)
+
+var (
+	numericDefaultPattern = regexp.MustCompile(`^[-+]?\d+(\.\d+)?$`)
+	stringDefaultPattern  = regexp.MustCompile(`^N?'((?:[^']|'')*)'$`)
+	identifierPattern     = regexp.MustCompile("`((?:[^`]|``)+)`")
+	fractionPattern       = regexp.MustCompile(`\((\d)\)$`)
+)
+
+// currentTimeDefaults - T-SQL functions of the current time allowed as MySQL defaults
+var currentTimeDefaults = []string{"getdate()", "sysdatetime()", "current_timestamp"}
+
+// unwrapDefault - strip the parentheses SQL Server wraps default expressions in, ((0)) to 0
+func unwrapDefault(def string) string {
+	def = strings.TrimSpace(def)
+	for strings.HasPrefix(def, "(") && strings.HasSuffix(def, ")") {
+		depth := 0
+		for i, c := range def {
+			if c == '(' {
+				depth += 1
+			} else if c == ')' {
+				depth -= 1
+			}
+			// closed before the end, (a)+(b)
+			if depth == 0 && i < len(def)-1 {
+				return def
+			}
+		}
+		def = strings.TrimSpace(def[1 : len(def)-1])
+	}
+	return def
+}
+
+// translateDefault - MySQL default of the T-SQL default expression, false when it can not be translated
+func translateDefault(def string, datatype string) (string, bool) {
+	def = unwrapDefault(def)
+	datatype = strings.ToLower(datatype)
+	if len(def) <= 0 || strings.EqualFold(def, "null") {
+		return "", true
+	}
+	// text, blob and json columns take no literal default
+	if strings.HasSuffix(datatype, "text") || strings.HasSuffix(datatype, "blob") || datatype == "json" {
+		return "", false
+	}
+
+	if numericDefaultPattern.MatchString(def) {
+		return def, true
+	} else if m := stringDefaultPattern.FindStringSubmatch(def); m != nil {
+		return "'" + strings.ReplaceAll(m[1], `\`, `\\`) + "'", true
+	} else if containsName(currentTimeDefaults, def) {
+		if strings.HasPrefix(datatype, "datetime") || strings.HasPrefix(datatype, "timestamp") {
+			if m := fractionPattern.FindStringSubmatch(datatype); m != nil {
+				return fmt.Sprintf("CURRENT_TIMESTAMP(%s)", m[1]), true
+			}
+			return "CURRENT_TIMESTAMP", true
+		}
+	}
+	return "", false
+}
+
+// nonDeterministicFunctions - functions MySQL rejects in generated columns, and T-SQL ones left untranslated
+var nonDeterministicFunctions = []string{
+	"NOW", "SYSDATE", "CURDATE", "CURTIME", "CURRENT_DATE", "CURRENT_TIME", "CURRENT_TIMESTAMP",
+	"LOCALTIME", "LOCALTIMESTAMP", "UTC_DATE", "UTC_TIME", "UTC_TIMESTAMP", "UNIX_TIMESTAMP",
+	"RAND", "UUID", "UUID_SHORT", "CONNECTION_ID", "CURRENT_USER", "USER", "SESSION_USER", "SYSTEM_USER",
+	"DATABASE", "SCHEMA", "LAST_INSERT_ID", "FOUND_ROWS", "ROW_COUNT", "NEWID", "NEWSEQUENTIALID",
+}
+
+// translateExpression - MySQL expression of the T-SQL computed column expression,
+// false when it calls a function a generated column can not
+func translateExpression(expr string) (string, bool) {
+	translated := translateTSQL(expr)
+	for _, t := range tokenizeSQL(translated) {
+		if t.Kind == tokenWord && t.is(nonDeterministicFunctions...) {
+			return translated, false
+		}
+	}
+	return translated, true
+}
+
+// renameIdentifiers - quoted identifiers of the expression named by the target columns,
+// false when it references a column not copied
+func renameIdentifiers(expr string, mappings []ColumnMapping) (string, bool) {
+	found := true
+	renamed := identifierPattern.ReplaceAllStringFunc(expr, func(quoted string) string {
+		name := strings.ReplaceAll(quoted[1:len(quoted)-1], "``", "`")
+		for _, m := range mappings {
+			if strings.EqualFold(m.Source.Name, name) && !m.Target.Computed {
+				return quoteMySQL(m.Target.Name)
+			}
+		}
+		found = false
+		return quoted
+	})
+	return renamed, found
+}
+
+// translateIdentity - AUTO_INCREMENT on an integer target, numeric(p,0) identities as bigint.
+// other identities are copied as plain columns
+func translateIdentity(target *ColumnDefinition) {
+	if isIntegerType(target.DataType) {
+		return
+	}
+	if strings.HasPrefix(strings.ToLower(target.DataType), "decimal(") && strings.HasSuffix(target.DataType, ",0)") {
+		target.DataType = "bigint"
+		return
+	}
+	fmt.Printf("    identity [%s] %s is not an integer, copied without AUTO_INCREMENT\n", target.Name, target.DataType)
+	target.Identity = false
+}
+
+// translateColumns - translate identity, default and computed columns of the target.
+// transformed columns keep neither
+func translateColumns(mappings []ColumnMapping, computed string) []ColumnMapping {
+	for i, m := range mappings {
+		target := &mappings[i].Target
+		target.Default = ""
+		if m.Transform != nil {
+			target.Identity, target.Computed, target.Expression = false, false, ""
+			continue
+		}
+		if target.Identity {
+			translateIdentity(target)
+		}
+		if def, ok := translateDefault(m.Source.Default, target.DataType); ok {
+			target.Default = def
+		} else {
+			fmt.Printf("    default %s of [%s] is not translated\n", m.Source.Default, m.Source.Name)
+		}
+	}
+
+	for i, m := range mappings {
+		if !m.Source.Computed {
+			continue
+		}
+		target := &mappings[i].Target
+		target.Identity, target.Default = false, ""
+		target.Computed, target.Expression = false, ""
+		if m.Transform != nil || strings.EqualFold(computed, ComputedMaterialize) {
+			continue
+		}
+		expr, deterministic := translateExpression(m.Source.Expression)
+		if !deterministic {
+			fmt.Printf("    computed [%s] is not deterministic, materialized\n", m.Source.Name)
+			continue
+		}
+		if expr, ok := renameIdentifiers(expr, mappings); ok {
+			target.Computed, target.Expression = true, expr
+		} else {
+			fmt.Printf("    computed [%s] references columns not copied, materialized\n", m.Source.Name)
+		}
+	}
+	return mappings
+}
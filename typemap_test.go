@@ -0,0 +1,213 @@
+package main
+
+import (
+	"testing"
+)
+
+type TypeMapSample struct {
+	Source mssqlType
+	Expect string
+	Origin string
+}
+
+func TestMapMSSQLType(t *testing.T) {
+	samples := []TypeMapSample{
+		{mssqlType{"bigint", 19, 0}, "bigint", "bigint"},
+		{mssqlType{"int", 10, 0}, "int", "int"},
+		{mssqlType{"smallint", 5, 0}, "smallint", "smallint"},
+		{mssqlType{"tinyint", 3, 0}, "tinyint unsigned", "tinyint"},
+		{mssqlType{"bit", 1, 0}, "tinyint(1)", "bit"},
+		{mssqlType{"decimal", 18, 2}, "decimal(18,2)", "decimal(18,2)"},
+		{mssqlType{"numeric", 38, 10}, "decimal(38,10)", "numeric(38,10)"},
+		{mssqlType{"numeric", 10, 0}, "decimal(10,0)", "numeric(10,0)"},
+		{mssqlType{"money", 19, 4}, "decimal(19,4)", "money"},
+		{mssqlType{"smallmoney", 10, 4}, "decimal(10,4)", "smallmoney"},
+		{mssqlType{"float", 53, 0}, "double", "float"},
+		{mssqlType{"float", 24, 0}, "float", "float"},
+		{mssqlType{"real", 24, 0}, "float", "real"},
+		{mssqlType{"date", 10, 0}, "date", "date"},
+		{mssqlType{"time", 16, 7}, "time(6)", "time(7)"},
+		{mssqlType{"time", 8, 0}, "time", "time(0)"},
+		{mssqlType{"datetime", 23, 3}, "datetime(3)", "datetime"},
+		{mssqlType{"datetime2", 27, 7}, "datetime(6)", "datetime2(7)"},
+		{mssqlType{"datetime2", 22, 3}, "datetime(3)", "datetime2(3)"},
+		{mssqlType{"smalldatetime", 16, 0}, "datetime", "smalldatetime"},
+		{mssqlType{"datetimeoffset", 34, 7}, "datetime(6)", "datetimeoffset(7)"},
+		{mssqlType{"char", 10, 0}, "char(10)", "char(10)"},
+		{mssqlType{"char", 1000, 0}, "text", "char(1000)"},
+		{mssqlType{"nchar", 2, 0}, "char(2)", "nchar(2)"},
+		{mssqlType{"varchar", 50, 0}, "varchar(50)", "varchar(50)"},
+		{mssqlType{"varchar", 4000, 0}, "text", "varchar(4000)"},
+		{mssqlType{"varchar", 2147483647, 0}, "longtext", "varchar(max)"},
+		{mssqlType{"varchar", -1, 0}, "longtext", "varchar(max)"},
+		{mssqlType{"nvarchar", 100, 0}, "varchar(100)", "nvarchar(100)"},
+		{mssqlType{"nvarchar", 1073741823, 0}, "longtext", "nvarchar(max)"},
+		{mssqlType{"text", 2147483647, 0}, "longtext", "text"},
+		{mssqlType{"ntext", 1073741823, 0}, "longtext", "ntext"},
+		{mssqlType{"sysname", 128, 0}, "varchar(128)", "sysname"},
+		{mssqlType{"binary", 16, 0}, "binary(16)", "binary(16)"},
+		{mssqlType{"binary", 1000, 0}, "blob", "binary(1000)"},
+		{mssqlType{"varbinary", 100, 0}, "varbinary(100)", "varbinary(100)"},
+		{mssqlType{"varbinary", 2147483647, 0}, "longblob", "varbinary(max)"},
+		{mssqlType{"image", 2147483647, 0}, "longblob", "image"},
+		{mssqlType{"timestamp", 8, 0}, "binary(8)", "timestamp"},
+		{mssqlType{"rowversion", 8, 0}, "binary(8)", "rowversion"},
+		{mssqlType{"uniqueidentifier", 36, 0}, "char(36)", "uniqueidentifier"},
+		{mssqlType{"xml", 0, 0}, "longtext", "xml"},
+		{mssqlType{"sql_variant", 0, 0}, "text", "sql_variant"},
+		{mssqlType{"hierarchyid", 892, 0}, "varbinary(892)", "hierarchyid"},
+		{mssqlType{"geography", 0, 0}, "longblob", "geography"},
+		{mssqlType{"geometry", 0, 0}, "longblob", "geometry"},
+		{mssqlType{"unheard", 0, 0}, "longtext", "unheard"},
+	}
+
+	for i, s := range samples {
+		if mapped := mapMSSQLType(s.Source); mapped != s.Expect {
+			t.Errorf("[%d] %s expected %s but %s", i, s.Source.Name, s.Expect, mapped)
+		}
+		if origin := s.Source.String(); origin != s.Origin {
+			t.Errorf("[%d] %s expected %s but %s", i, s.Source.Name, s.Origin, origin)
+		}
+	}
+}
+
+func TestBuildMSSQLColumnDefinition(t *testing.T) {
+	// sp_columns row, TABLE_QUALIFIER .. NULLABLE
+	row := []interface{}{"CheilOptimizer_DM", "dbo", "Cleansed_Dataset", "Planned cost_usd", int64(3),
+		"numeric", int64(18), int64(20), int64(4), int64(10), int64(1)}
+	col := buildMSSQLColumnDefinition(row)
+	if col.Name != "Planned cost_usd" || col.DataType != "decimal(18,4)" || !col.Nullable || col.SourceType != "numeric(18,4)" {
+		t.Errorf("numeric column %v", col)
+	}
+
+	// identity, scale and radix NULL
+	row = []interface{}{"CheilOptimizer_DM", "dbo", "Cleansed_Dataset", "id", int64(4),
+		"int identity", int64(10), int64(4), int64(0), int64(10), int64(0)}
+	if col = buildMSSQLColumnDefinition(row); col.DataType != "int" || col.Nullable {
+		t.Errorf("identity column %v", col)
+	}
+
+	row = []interface{}{"CheilOptimizer_DM", "dbo", "Cleansed_Dataset", "seq", int64(2),
+		"numeric() identity", int64(18), int64(20), int64(0), int64(10), int64(0)}
+	if col = buildMSSQLColumnDefinition(row); col.DataType != "decimal(18,0)" || col.SourceType != "numeric(18,0)" || !col.Identity {
+		t.Errorf("numeric identity column %v", col)
+	}
+
+	row = []interface{}{"CheilOptimizer_DM", "dbo", "Cleansed_Dataset", "Description", int64(-9),
+		"nvarchar", int64(1073741823), int64(2147483646), nil, nil, int64(1)}
+	if col = buildMSSQLColumnDefinition(row); col.DataType != "longtext" || col.SourceType != "nvarchar(max)" {
+		t.Errorf("nvarchar(max) column %v", col)
+	}
+}
+
+func TestOverrideTypes(t *testing.T) {
+	mappings := mapColumns([]ColumnDefinition{
+		{Name: "payload", DataType: "text", SourceType: "varchar(4000)"},
+		{Name: "clicks", DataType: "decimal(18,0)", SourceType: "numeric(18,0)"},
+		{Name: "rate", DataType: "decimal(18,4)", SourceType: "numeric(18,4)"},
+		{Name: "memo", DataType: "text", SourceType: "varchar(4000)"},
+	}, ColumnSelection{})
+	overrides := []TypeOverride{
+		{SourceType: "varchar(4000)", Column: "payload", Type: "json"},
+		{SourceType: "numeric(*,0)", Type: "bigint"},
+		{SourceType: "numeric*", Type: "double"},
+	}
+	mappings = overrideTypes(mappings, map[string]string{"RATE": "decimal(20,6)"}, overrides)
+
+	expects := []string{"json", "bigint", "decimal(20,6)", "text"}
+	for i, m := range mappings {
+		if m.Target.DataType != expects[i] {
+			t.Errorf("[%d] %s expected %s but %s", i, m.Source.Name, expects[i], m.Target.DataType)
+		}
+		if m.Overridden != (i < 3) {
+			t.Errorf("[%d] %s overridden flag %v", i, m.Source.Name, m.Overridden)
+		}
+	}
+}
+
+func TestTranslateDefault(t *testing.T) {
+	samples := []struct {
+		Default  string
+		DataType string
+		Expect   string
+		Ok       bool
+	}{
+		{"", "int", "", true},
+		{"((0))", "int", "0", true},
+		{"((-1.5))", "decimal(10,2)", "-1.5", true},
+		{"('N/A')", "varchar(10)", "'N/A'", true},
+		{"(N'한국')", "varchar(10)", "'한국'", true},
+		{"('it''s')", "varchar(10)", "'it''s'", true},
+		{"('C:\\temp')", "varchar(10)", "'C:\\\\temp'", true},
+		{"(NULL)", "int", "", true},
+		{"(getdate())", "datetime(3)", "CURRENT_TIMESTAMP(3)", true},
+		{"(getdate())", "datetime", "CURRENT_TIMESTAMP", true},
+		{"(getdate())", "date", "", false},
+		{"(newid())", "char(36)", "", false},
+		{"('memo')", "longtext", "", false},
+		{"((1)+(2))", "int", "", false},
+	}
+	for i, s := range samples {
+		def, ok := translateDefault(s.Default, s.DataType)
+		if def != s.Expect || ok != s.Ok {
+			t.Errorf("[%d] %s expected %s(%v) but %s(%v)", i, s.Default, s.Expect, s.Ok, def, ok)
+		}
+	}
+}
+
+func TestTranslateColumns(t *testing.T) {
+	mappings := mapColumns([]ColumnDefinition{
+		{Name: "id", DataType: "int", Identity: true},
+		{Name: "Clicks", DataType: "int", Default: "((0))"},
+		{Name: "Impressions", DataType: "int"},
+		{Name: "CTR", DataType: "decimal(10,4)", Computed: true, Expression: "([Clicks]/[Impressions])", Persisted: true},
+		{Name: "Audit", DataType: "int", Computed: true, Expression: "([audit_user]+(1))"},
+		{Name: "Age", DataType: "int", Computed: true, Expression: "(datediff(day,[Clicks],getdate()))"},
+	}, ColumnSelection{Rename: map[string]string{"Clicks": "clicks"}})
+
+	generated := translateColumns(append([]ColumnMapping{}, mappings...), ComputedGenerated)
+	if !generated[0].Target.Identity || generated[1].Target.Default != "0" {
+		t.Errorf("identity and default expected but %v %v", generated[0].Target, generated[1].Target)
+	}
+	if !generated[3].Target.Computed || generated[3].Target.Expression != "(`clicks`/`Impressions`)" {
+		t.Errorf("generated column expected but %v", generated[3].Target)
+	}
+	if generated[4].Target.Computed {
+		t.Error("computed column on an unknown column expected to materialize")
+	}
+	if generated[5].Target.Computed {
+		t.Errorf("computed column on GETDATE() expected to materialize but %v", generated[5].Target)
+	}
+
+	identities := translateColumns(mapColumns([]ColumnDefinition{
+		{Name: "seq", DataType: "decimal(18,0)", Identity: true},
+		{Name: "code", DataType: "longtext", Identity: true},
+		{Name: "tiny", DataType: "tinyint unsigned", Identity: true},
+	}, ColumnSelection{}), ComputedGenerated)
+	if identities[0].Target.DataType != "bigint" || !identities[0].Target.Identity {
+		t.Errorf("numeric identity expected as bigint but %v", identities[0].Target)
+	}
+	if identities[1].Target.Identity || !identities[2].Target.Identity {
+		t.Errorf("identity expected on integers only but %v %v", identities[1].Target, identities[2].Target)
+	}
+	if q := (TableDefinition{}).columnQuery(ColumnDefinition{Name: "code", DataType: "longtext", Identity: true}); q != "`code` longtext NOT NULL" {
+		t.Errorf("AUTO_INCREMENT expected on integers only but %s", q)
+	}
+
+	materialized := translateColumns(append([]ColumnMapping{}, mappings...), ComputedMaterialize)
+	if materialized[3].Target.Computed {
+		t.Error("computed column expected to materialize")
+	}
+
+	td := TableDefinition{Name: "t", Columns: []ColumnDefinition{generated[0].Target, generated[1].Target, generated[3].Target}}
+	expect := "CREATE TABLE IF NOT EXISTS `t` (`id` int NOT NULL AUTO_INCREMENT,`clicks` int NOT NULL DEFAULT 0," +
+		"`CTR` decimal(10,4) AS ((`clicks`/`Impressions`)) STORED NOT NULL)"
+	if q := td.CreateQuery(); q != expect {
+		t.Errorf("expected %s but %s", expect, q)
+	}
+
+	tt := TransferTask{Setting: TableTransferSetting{Name: "t", Index: "id"}, Columns: generated}
+	if q := tt.insertQuery(); q != "INSERT INTO `t` (`id`,`clicks`,`Impressions`,`Audit`,`Age`) VALUES (?,?,?,?,?)" {
+		t.Errorf("generated column expected out of the insert but %s", q)
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	VerifyMatched   = "matched"   // same results on the source and the target
+	VerifyDivergent = "divergent" // results differ
+	VerifyMissing   = "missing"   // no target view or table of the name
+	VerifyFailed    = "failed"    // not queried
+
+	VerifyHash      = "hash"      // every row hashed and compared
+	VerifyAggregate = "aggregate" // row counts and column aggregates compared
+
+	DefaultVerifyRows = 1000 // views of up to the rows are compared row by row
+)
+
+// ViewVerification - result parity of a source view and its target view
+type ViewVerification struct {
+	Database    string   `json:"database"`
+	Name        string   `json:"name"`
+	Target      string   `json:"target"`
+	Status      string   `json:"status"`
+	Method      string   `json:"method,omitempty"`
+	SourceRows  int64    `json:"source_rows"`
+	TargetRows  int64    `json:"target_rows"`
+	Differences []string `json:"differences,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// RunViews runs the views subcommand, copying the views without arguments or verify [--json]
+func RunViews(args ...string) {
+	if len(args) <= 0 {
+		RunTransferViews()
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "verify":
+		asJSON := 1 < len(args) && args[1] == "--json"
+		out := io.Writer(os.Stdout)
+		if asJSON {
+			var restore func()
+			out, restore = jsonOutput()
+			defer restore()
+		}
+		VerifyViews(out, asJSON)
+	default:
+		log.Fatalf("invalid views command : %s", args[0])
+	}
+}
+
+// canonicalTimeLayout - times compared on the wall clock
+const canonicalTimeLayout = "2006-01-02 15:04:05.999999999"
+
+// canonicalValue - value comparable between the drivers. numbers, times and booleans are formatted alike
+// and the trailing blanks of fixed-length strings are left out
+func canonicalValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "\x00"
+	case bool:
+		if value {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return value.Format(canonicalTimeLayout)
+	case float64:
+		return strconv.FormatFloat(value, 'g', 15, 64)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'g', 15, 64)
+	}
+	s := strings.TrimRight(transformString(v), " ")
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.FormatFloat(f, 'g', 15, 64)
+	}
+	for _, layout := range []string{canonicalTimeLayout, "2006-01-02"} {
+		if at, err := time.Parse(layout, s); err == nil {
+			return at.Format(canonicalTimeLayout)
+		}
+	}
+	return s
+}
+
+// convertSourceRows - source values converted as the copy converts them, GUIDs as strings
+// and times in the target zone
+func convertSourceRows(columns []ColumnDefinition, rows [][]interface{}, zones TimeZones) [][]interface{} {
+	for _, row := range rows {
+		for i := range row {
+			if i < len(columns) {
+				row[i] = convertValue(columns[i], row[i], zones)
+			}
+		}
+	}
+	return rows
+}
+
+// rowHash - hash of the canonical values of the row
+func rowHash(row []interface{}) string {
+	values := make([]string, len(row))
+	for i, v := range row {
+		values[i] = canonicalValue(v)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(values, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// compareRowHashes - rows found only on the source and only on the target, duplicates counted
+func compareRowHashes(source [][]interface{}, target [][]interface{}) (int, int) {
+	counts := make(map[string]int, len(source))
+	for _, row := range source {
+		counts[rowHash(row)] += 1
+	}
+	for _, row := range target {
+		counts[rowHash(row)] -= 1
+	}
+	onlySource, onlyTarget := 0, 0
+	for _, count := range counts {
+		if 0 < count {
+			onlySource += count
+		} else {
+			onlyTarget -= count
+		}
+	}
+	return onlySource, onlyTarget
+}
+
+// isNumericSourceType - whether the SQL Server type is summed
+func isNumericSourceType(sourceType string) bool {
+	name := strings.SplitN(strings.ToLower(sourceType), "(", 2)[0]
+	switch name {
+	case "tinyint", "smallint", "int", "bigint", "decimal", "numeric", "float", "real", "money", "smallmoney":
+		return true
+	}
+	return false
+}
+
+// isComparableSourceType - whether the SQL Server type is counted distinctly
+func isComparableSourceType(sourceType string) bool {
+	name := strings.SplitN(strings.ToLower(sourceType), "(", 2)[0]
+	switch name {
+	case "text", "ntext", "image", "xml", "sql_variant":
+		return false
+	}
+	return true
+}
+
+// aggregateExpressions - labels and aggregates of the columns, T-SQL on the source and MySQL on the target.
+// the row count leads, the target columns are matched by position
+func aggregateExpressions(columns []ColumnDefinition, live []ColumnDefinition) ([]string, []string, []string) {
+	labels := []string{"rows"}
+	sources := []string{"COUNT_BIG(*)"}
+	targets := []string{"COUNT(*)"}
+	for i, col := range columns {
+		s, t := quoteMSSQL(col.Name), quoteMySQL(live[i].Name)
+		labels = append(labels, fmt.Sprintf("count(%s)", col.Name))
+		sources = append(sources, fmt.Sprintf("COUNT_BIG(%s)", s))
+		targets = append(targets, fmt.Sprintf("COUNT(%s)", t))
+		if isComparableSourceType(col.SourceType) {
+			labels = append(labels, fmt.Sprintf("distinct(%s)", col.Name))
+			sources = append(sources, fmt.Sprintf("COUNT_BIG(DISTINCT %s)", s))
+			targets = append(targets, fmt.Sprintf("COUNT(DISTINCT %s)", t))
+		}
+		if isNumericSourceType(col.SourceType) {
+			labels = append(labels, fmt.Sprintf("sum(%s)", col.Name))
+			sources = append(sources, fmt.Sprintf("SUM(CAST(%s AS float))", s))
+			targets = append(targets, fmt.Sprintf("SUM(%s)", t))
+		}
+	}
+	return labels, sources, targets
+}
+
+// sameAggregate - whether the aggregates are equal, numbers within a relative tolerance
+func sameAggregate(source interface{}, target interface{}) bool {
+	s, t := canonicalValue(source), canonicalValue(target)
+	if s == t {
+		return true
+	}
+	sf, serr := strconv.ParseFloat(s, 64)
+	tf, terr := strconv.ParseFloat(t, 64)
+	if serr != nil || terr != nil {
+		return false
+	}
+	return math.Abs(sf-tf) <= 1e-9*math.Max(1, math.Max(math.Abs(sf), math.Abs(tf)))
+}
+
+// compareAggregates - differences of the aggregates, label source -> target
+func compareAggregates(labels []string, source []interface{}, target []interface{}) []string {
+	diffs := make([]string, 0)
+	for i, label := range labels {
+		if i < len(source) && i < len(target) && !sameAggregate(source[i], target[i]) {
+			diffs = append(diffs, fmt.Sprintf("%s %s -> %s", label, canonicalValue(source[i]), canonicalValue(target[i])))
+		}
+	}
+	return diffs
+}
+
+// verifyView - compare the results of the source view and its target view,
+// row by row up to the limit of rows and by column aggregates over it
+func (vt ViewTask) verifyView(name string, limit int64) ViewVerification {
+	v := ViewVerification{Database: vt.Database, Name: name, Target: vt.targetView(name)}
+	sourceView := fmt.Sprintf("%s.%s", quoteMSSQL(vt.viewOwner(name)), quoteMSSQL(name))
+	targetView := quoteMySQL(v.Target)
+
+	columns := readMSSQLTableColumns(vt.Source, vt.viewOwner(name), name)
+	live := readMySQLTableColumns(vt.Target, v.Target)
+	if len(live) <= 0 {
+		v.Status = VerifyMissing
+		return v
+	}
+	if len(columns) != len(live) {
+		v.Status = VerifyDivergent
+		v.Differences = append(v.Differences, fmt.Sprintf("columns %d -> %d", len(columns), len(live)))
+		return v
+	}
+
+	labels, sources, targets := aggregateExpressions(columns, live)
+	sourceAggregates, err := queryFetchAll(vt.Source, fmt.Sprintf("SELECT %s FROM %s", strings.Join(sources, ","), sourceView))
+	if err != nil || len(sourceAggregates) <= 0 {
+		v.Status, v.Error = VerifyFailed, fmt.Sprintf("source: %v", err)
+		return v
+	}
+	targetAggregates, err := queryFetchAll(vt.Target, fmt.Sprintf("SELECT %s FROM %s", strings.Join(targets, ","), targetView))
+	if err != nil || len(targetAggregates) <= 0 {
+		v.Status, v.Error = VerifyFailed, fmt.Sprintf("target: %v", err)
+		return v
+	}
+	v.SourceRows, _ = strconv.ParseInt(canonicalValue(sourceAggregates[0][0]), 10, 64)
+	v.TargetRows, _ = strconv.ParseInt(canonicalValue(targetAggregates[0][0]), 10, 64)
+
+	if v.SourceRows <= limit && v.TargetRows <= limit {
+		v.Method = VerifyHash
+		sourceNames, targetNames := make([]string, len(columns)), make([]string, len(live))
+		for i := range columns {
+			sourceNames[i], targetNames[i] = quoteMSSQL(columns[i].Name), quoteMySQL(live[i].Name)
+		}
+		sourceRows, err := queryFetchAll(vt.Source, fmt.Sprintf("SELECT %s FROM %s", strings.Join(sourceNames, ","), sourceView))
+		if err != nil {
+			v.Status, v.Error = VerifyFailed, "source: "+err.Error()
+			return v
+		}
+		targetRows, err := queryFetchAll(vt.Target, fmt.Sprintf("SELECT %s FROM %s", strings.Join(targetNames, ","), targetView))
+		if err != nil {
+			v.Status, v.Error = VerifyFailed, "target: "+err.Error()
+			return v
+		}
+		if onlySource, onlyTarget := compareRowHashes(convertSourceRows(columns, sourceRows, vt.Zones), targetRows); 0 < onlySource+onlyTarget {
+			v.Differences = append(v.Differences, fmt.Sprintf("rows %d -> %d, %d only on the source, %d only on the target",
+				v.SourceRows, v.TargetRows, onlySource, onlyTarget))
+		}
+	} else {
+		v.Method = VerifyAggregate
+		v.Differences = compareAggregates(labels, sourceAggregates[0], targetAggregates[0])
+	}
+
+	v.Status = VerifyMatched
+	if 0 < len(v.Differences) {
+		v.Status = VerifyDivergent
+	}
+	return v
+}
+
+// VerifyViews compares the results of the selected source views and their target views,
+// written to out as text or JSON
+func VerifyViews(out io.Writer, asJSON bool) []ViewVerification {
+	settings := GetConfigure(ConfigPath)
+	limit := int64(settings.Views.VerifyRows)
+	if limit <= 0 {
+		limit = DefaultVerifyRows
+	}
+
+	schemas := make([]string, 0, len(settings.Targets))
+	for schema := range settings.Targets {
+		schemas = append(schemas, schema)
+	}
+	sort.Strings(schemas)
+
+	verifications := make([]ViewVerification, 0)
+	for _, schema := range schemas {
+		// open and close source
+		source, _ := OpenConnection(settings.Connectors[KEY_CNX_SOURCE], schema)
+		defer source.Close()
+		// open and close target
+		database := settings.TargetDatabase(schema)
+		target, _ := OpenConnection(settings.Connectors[KEY_CNX_TARGET], database)
+		defer target.Close()
+
+		fmt.Printf("DB %s -> %s\n", schema, database)
+		vt := ViewTask{Source: source, Target: target, Schema: schema, Database: database, Setting: settings.Views,
+			Zones: settings.TimeZones(), Owners: readMSSQLViewOwners(source)}
+		views := make(map[string]string)
+		for name, def := range listMSSQLViews(source) {
+			if settings.Views.Selected(name) {
+				views[name] = def
+			}
+		}
+		vt.selectViews(views)
+		names := make([]string, 0, len(views))
+		for name := range views {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			verifications = append(verifications, vt.verifyView(name, limit))
+		}
+	}
+
+	if asJSON {
+		contents, _ := json.MarshalIndent(verifications, "", "  ")
+		fmt.Fprintln(out, string(contents))
+	} else {
+		counts := make(map[string]int)
+		for _, v := range verifications {
+			v.Print(out)
+			counts[v.Status] += 1
+		}
+		fmt.Fprintf(out, "views verify: %d matched, %d divergent, %d missing, %d failed\n",
+			counts[VerifyMatched], counts[VerifyDivergent], counts[VerifyMissing], counts[VerifyFailed])
+	}
+	return verifications
+}
+
+// Print prints the parity of the view and its differences
+func (v ViewVerification) Print(out io.Writer) {
+	name := v.Name
+	if v.Target != v.Name {
+		name += " -> " + v.Target
+	}
+	switch v.Status {
+	case VerifyMissing:
+		fmt.Fprintf(out, "VIEW %s %s\n", name, v.Status)
+	case VerifyFailed:
+		fmt.Fprintf(out, "VIEW %s %s: %s\n", name, v.Status, v.Error)
+	default:
+		fmt.Fprintf(out, "VIEW %s %s (%s, %d rows)\n", name, v.Status, v.Method, v.SourceRows)
+	}
+	for _, d := range v.Differences {
+		fmt.Fprintf(out, "  ~ %s\n", d)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCanonicalValue(t *testing.T) {
+	at := time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	samples := []struct {
+		Source interface{}
+		Target interface{}
+	}{
+		{int64(52), []byte("52")},
+		{[]byte("12.50"), []byte("12.5")},
+		{true, []byte("1")},
+		{at, []byte("2021-05-10 12:00:00")},
+		{time.Date(2021, 5, 10, 0, 0, 0, 0, time.UTC), []byte("2021-05-10")},
+		{"AO  ", []byte("AO")},
+		{nil, nil},
+	}
+	for i, s := range samples {
+		if canonicalValue(s.Source) != canonicalValue(s.Target) {
+			t.Errorf("[%d] %v expected same as %v but %q, %q", i, s.Source, s.Target, canonicalValue(s.Source), canonicalValue(s.Target))
+		}
+	}
+	if canonicalValue(nil) == canonicalValue("") || canonicalValue("a") == canonicalValue("A") {
+		t.Errorf("NULL, empty and cases expected apart")
+	}
+}
+
+func TestCompareRowHashes(t *testing.T) {
+	source := [][]interface{}{{"a", int64(1)}, {"a", int64(1)}, {"b", nil}}
+	target := [][]interface{}{{[]byte("a"), []byte("1")}, {[]byte("b"), []byte("")}, {[]byte("c"), nil}}
+	if onlySource, onlyTarget := compareRowHashes(source, target); onlySource != 2 || onlyTarget != 2 {
+		t.Errorf("expected 2 and 2 but %d and %d", onlySource, onlyTarget)
+	}
+	if onlySource, onlyTarget := compareRowHashes(source, source); onlySource != 0 || onlyTarget != 0 {
+		t.Errorf("expected the same rows")
+	}
+}
+
+func TestCompareAggregates(t *testing.T) {
+	columns := []ColumnDefinition{
+		{Name: "Country", SourceType: "nvarchar(50)"},
+		{Name: "Actual cost_usd", SourceType: "decimal(18,2)"},
+		{Name: "Description", SourceType: "ntext"},
+	}
+	live := []ColumnDefinition{{Name: "Country"}, {Name: "Actual_cost_usd"}, {Name: "Description"}}
+	labels, sources, targets := aggregateExpressions(columns, live)
+	if len(labels) != 7 || sources[4] != "COUNT_BIG(DISTINCT [Actual cost_usd])" || targets[5] != "SUM(`Actual_cost_usd`)" {
+		t.Errorf("unexpected %v %v %v", labels, sources, targets)
+	}
+
+	source := []interface{}{int64(3), int64(3), int64(2), int64(3), int64(3), float64(1234.5600000001), int64(1)}
+	target := []interface{}{[]byte("3"), []byte("3"), []byte("1"), []byte("3"), []byte("3"), []byte("1234.56"), []byte("1")}
+	diffs := compareAggregates(labels, source, target)
+	if len(diffs) != 1 || diffs[0] != "distinct(Country) 2 -> 1" {
+		t.Errorf("unexpected %v", diffs)
+	}
+}
+
+func TestConvertSourceRows(t *testing.T) {
+	seoul := time.FixedZone("+09:00", 9*60*60)
+	columns := []ColumnDefinition{{Name: "id", SourceType: "uniqueidentifier"}, {Name: "at", SourceType: "datetime"}}
+	// 6F9619FF-8B86-D011-B42D-00C04FC964FF in SQL Server byte order
+	guid := []byte{0xFF, 0x19, 0x96, 0x6F, 0x86, 0x8B, 0x11, 0xD0, 0xB4, 0x2D, 0x00, 0xC0, 0x4F, 0xC9, 0x64, 0xFF}
+	source := [][]interface{}{{guid, time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)}}
+	target := [][]interface{}{{[]byte("6F9619FF-8B86-D011-B42D-00C04FC964FF"), []byte("2021-05-10 03:00:00")}}
+	if onlySource, onlyTarget := compareRowHashes(convertSourceRows(columns, source, TimeZones{Source: seoul}), target); onlySource != 0 || onlyTarget != 0 {
+		t.Errorf("converted rows expected alike but %v", source)
+	}
+
+	var out bytes.Buffer
+	ViewVerification{Name: "v", Target: "v", Status: VerifyDivergent, Method: VerifyHash, SourceRows: 1, Differences: []string{"rows 1 -> 1"}}.Print(&out)
+	if out.String() != "VIEW v divergent (hash, 1 rows)\n  ~ rows 1 -> 1\n" {
+		t.Errorf("unexpected %q", out.String())
+	}
+}
+
+func TestVerifyViewsJSON(t *testing.T) {
+	sql.Register("fake_verify_source", fakeDriver{"SELECT name, object_definition(object_id) FROM sys.views": {
+		columns: []string{"name", "definition"},
+		values:  [][]driver.Value{{"v_orders", "CREATE VIEW v_orders AS SELECT 1 AS one"}},
+	}})
+	sql.Register("fake_verify_target", fakeDriver{})
+	ServiceConfig = &Settings{
+		Connectors: map[string]ConnectionSetting{
+			KEY_CNX_SOURCE: {Driver: "fake_verify_source"},
+			KEY_CNX_TARGET: {Driver: "fake_verify_target"},
+		},
+		Targets: map[string][]TableTransferSetting{"Cheil": {{Name: "orders", Index: "id"}}},
+	}
+	defer func() { ServiceConfig = nil }()
+
+	captured, err := ioutil.TempFile("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(captured.Name())
+	stdout := os.Stdout
+	os.Stdout = captured
+	RunViews("verify", "--json")
+	os.Stdout = stdout
+	captured.Close()
+
+	contents, err := ioutil.ReadFile(captured.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var verifications []ViewVerification
+	if err := json.Unmarshal(contents, &verifications); err != nil {
+		t.Fatalf("stdout expected to hold the JSON only but %s: %s", err, contents)
+	}
+	if len(verifications) != 1 || verifications[0].Status != VerifyMissing {
+		t.Errorf("unexpected %v", verifications)
+	}
+}
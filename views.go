@@ -0,0 +1,514 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	DependencyView     = "view"     // another view of the database
+	DependencyTable    = "table"    // a table of the database
+	DependencyMissing  = "missing"  // not found on the source
+	DependencyExternal = "external" // an object of another database
+)
+
+// ViewTask - replication of the views of a database
+type ViewTask struct {
+	Source   *sql.DB
+	Target   *sql.DB
+	Schema   string // source schema (database)
+	Database string // target database
+	Setting  ViewSetting
+	Views    map[string]string // target names of the selected views, by the source view(key) in lower case
+
+	Databases map[string]string // target databases of the replicated source databases(key) in lower case
+	Tables    map[string]string // target names of the tables configured by name, by database.owner.table(key) in lower case
+
+	Naming       NamingSetting     // normalization of the column names not recorded
+	Columns      map[string]string // recorded target names of the source columns
+	Fingerprints map[string]string // fingerprints of the applied views, nil when not recorded
+	Results      []ViewResult      // outcomes of the views on the run
+
+	Table       TableTransferSetting // defaults of the tables materialized views are copied into
+	Zones       TimeZones            // zones of the time values of materialized views
+	Transformed []string             // source table names and patterns with transforms
+	Owners      map[string]string    // source schema (owner) of the source views, by the view(key)
+}
+
+// viewOwner - source schema (owner) of the source view, dbo when not known
+func (vt ViewTask) viewOwner(name string) string {
+	if owner, exists := vt.Owners[name]; exists && 0 < len(owner) {
+		return owner
+	}
+	return DefaultSourceSchema
+}
+
+// ViewFingerprints - target database(key) per view(key) to the fingerprint of the applied definition
+type ViewFingerprints map[string]map[string]string
+
+// GetViewFingerprints - load the fingerprints recorded on the path, nil when not recorded
+func GetViewFingerprints(path string) ViewFingerprints {
+	if len(path) <= 0 {
+		return nil
+	}
+	fingerprints := ViewFingerprints{}
+	if err := LoadFromYaml(path, &fingerprints); err != nil && !os.IsNotExist(err) {
+		fmt.Println(err.Error())
+	}
+	return fingerprints
+}
+
+// Database - fingerprints of the database views, nil when not recorded
+func (vf ViewFingerprints) Database(database string) map[string]string {
+	if vf == nil {
+		return nil
+	}
+	if _, exists := vf[database]; !exists {
+		vf[database] = make(map[string]string)
+	}
+	return vf[database]
+}
+
+// fingerprintView - fingerprint of the view definition,
+// comments, spacing and keyword case left out
+func fingerprintView(query string) string {
+	var sb strings.Builder
+	for _, t := range tokenizeSQL(query) {
+		switch t.Kind {
+		case tokenComment:
+		case tokenSpace:
+			sb.WriteString(" ")
+		case tokenWord:
+			sb.WriteString(strings.ToUpper(t.Text))
+		default:
+			sb.WriteString(t.mysql())
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.TrimSpace(sb.String())))
+	return hex.EncodeToString(sum[:])
+}
+
+var createViewPattern = regexp.MustCompile(`(?is)^((?:\s+|--[^\n]*\n|/\*.*?\*/)*)CREATE\s+VIEW\b`)
+
+// orReplaceView - the create view statement as CREATE OR REPLACE VIEW
+func orReplaceView(query string) string {
+	return createViewPattern.ReplaceAllString(query, "${1}CREATE OR REPLACE VIEW")
+}
+
+// selectViews - target names of the source views selected, by the source view(key) in lower case
+func (vt *ViewTask) selectViews(views map[string]string) map[string]string {
+	targets := make(map[string]string, len(views))
+	for name := range views {
+		if vt.Setting.Selected(name) {
+			targets[strings.ToLower(name)] = vt.Setting.TargetView(vt.Schema, vt.Database, name)
+		}
+	}
+	vt.Views = targets
+	return targets
+}
+
+// targetView - target name of the source view
+func (vt ViewTask) targetView(name string) string {
+	if target, exists := vt.Views[strings.ToLower(name)]; exists {
+		return target
+	}
+	return name
+}
+
+// objectNames - target names of the objects the views select from
+func (vt ViewTask) objectNames() ObjectNames {
+	return ObjectNames{Schema: vt.Schema, Views: vt.Views, Databases: vt.Databases, Tables: vt.Tables}
+}
+
+// viewQuery - MySQL definition of the source view, on the target object and column names
+func (vt ViewTask) viewQuery(def string) string {
+	// object names are kept from the column names
+	columns := make(map[string]string, len(vt.Columns)+len(vt.Views)+len(vt.Databases)+len(vt.Tables))
+	for source, target := range vt.Columns {
+		columns[source] = target
+	}
+	for _, names := range []map[string]string{vt.Views, vt.Databases, vt.Tables} {
+		for _, target := range names {
+			columns[strings.ToLower(target)] = target
+		}
+	}
+	return renameViewColumns(translateView(def, vt.objectNames()), columns, vt.Naming)
+}
+
+// externalReferences - objects of the databases not replicated the view selects from, database.name
+func (vt ViewTask) externalReferences(deps []ViewDependency) []string {
+	refs := make([]string, 0)
+	for _, dep := range deps {
+		if dep.Kind != DependencyExternal || strings.EqualFold(dep.Database, vt.Schema) {
+			continue
+		}
+		if _, exists := vt.Databases[strings.ToLower(dep.Database)]; !exists {
+			refs = append(refs, dep.Database+"."+dep.Name)
+		}
+	}
+	return refs
+}
+
+// duplicateViews - create the selected source views missing on the target in dependency order,
+// replace the changed ones and drop the removed ones when fingerprints are recorded
+func (vt *ViewTask) duplicateViews() {
+	// list source views
+	allViews := listMSSQLViews(vt.Source)
+	vt.Owners = readMSSQLViewOwners(vt.Source)
+	oldViews := make(map[string]string, len(allViews))
+	for name, def := range allViews {
+		if vt.Setting.Selected(name) {
+			oldViews[name] = def
+		}
+	}
+	vt.selectViews(oldViews)
+	newViews := listMySQLViews(vt.Target, vt.Database)
+
+	// templates take the place of the source views of the same names
+	generated, failed := vt.generateViews()
+	generatedNames := make(map[string]bool, len(generated))
+	for _, view := range generated {
+		generatedNames[strings.ToLower(view.Name)] = true
+	}
+	replaced := 0
+	for name := range oldViews {
+		if generatedNames[strings.ToLower(vt.targetView(name))] {
+			delete(oldViews, name)
+			replaced += 1
+		}
+	}
+	if 0 < replaced {
+		fmt.Printf("  %d source views generated by templates\n", replaced)
+	}
+
+	deps := viewDependencies(vt.Source, allViews)
+	order, cyclic := sortViews(oldViews, deps)
+	for _, vname := range cyclic {
+		vt.report(ViewResult{Name: vname, Target: vt.targetView(vname), Status: ViewSkipped, Source: oldViews[vname],
+			Error: "in or depending on a dependency cycle"})
+	}
+	tables := readMySQLTableNames(vt.Target)
+
+	applied := make(map[string]string, len(order))
+	for _, view := range generated {
+		applied[view.Name] = view.Template
+		result := ViewResult{Name: view.Name, Target: view.Name, Template: view.Template, Status: ViewCreated, Translated: view.Definition}
+		vt.report(vt.applyView(result, newViews, nil))
+	}
+	for _, vname := range order {
+		target := vt.targetView(vname)
+		applied[target] = vname
+		if option := vt.Setting.Option(vname); option.Materialize {
+			// transformed values are not copied raw through the view
+			if tables := vt.transformedTables(vname, deps, map[string]bool{}); 0 < len(tables) && len(option.Transforms) <= 0 {
+				vt.report(ViewResult{Name: vname, Target: target, Status: ViewFailed, Source: oldViews[vname],
+					Error: "selects from tables with transforms (" + strings.Join(tables, ", ") + "), materialize takes transforms of its own"})
+				continue
+			}
+			vt.report(vt.materializeView(vname, target, newViews))
+			continue
+		}
+		warnings := make([]string, 0)
+		if refs := vt.externalReferences(deps[vname]); 0 < len(refs) {
+			warnings = append(warnings, "selects from databases not replicated: "+strings.Join(refs, ", "))
+		}
+		if sums := untypedSums(oldViews[vname]); 0 < len(sums) {
+			warnings = append(warnings, "+ between columns kept as addition, CONCAT when they are strings: "+strings.Join(sums, ", "))
+		}
+		if missing := missingTables(deps[vname], tables, vt.objectNames()); 0 < len(missing) {
+			warnings = append(warnings, "selects from tables missing on the target: "+strings.Join(missing, ", "))
+		}
+		for _, dep := range deps[vname] {
+			if _, selected := oldViews[dep.Name]; dep.Kind == DependencyView && !selected && !generatedNames[strings.ToLower(vt.targetView(dep.Name))] {
+				warnings = append(warnings, "selects from the view not copied: "+dep.Name)
+			}
+		}
+		result := ViewResult{Name: vname, Target: target, Source: oldViews[vname], Status: ViewCreated}
+		result.Translated = vt.viewQuery(oldViews[vname])
+		vt.report(vt.applyView(result, newViews, warnings))
+	}
+	for _, vname := range cyclic {
+		applied[vt.targetView(vname)] = vname
+	}
+
+	if vt.Setting.DropRemoved {
+		vt.dropRemovedViews(vt.keepTemplateViews(applied, failed), newViews)
+	}
+}
+
+// applyView - create the translated view missing on the target, or replace it when the fingerprint changed.
+// the warnings go with the view applied
+func (vt *ViewTask) applyView(result ViewResult, newViews map[string]string, warnings []string) ViewResult {
+	fingerprint := fingerprintView(result.Translated)
+	if _, exists := newViews[result.Target]; exists {
+		if vt.Fingerprints == nil || vt.Fingerprints[result.Target] == fingerprint {
+			result.Status = ViewUnchanged
+			return result
+		}
+		// changed since applied, or not recorded yet
+		result.Status = ViewReplaced
+		result.Translated = orReplaceView(result.Translated)
+	}
+	result.Warnings = append(result.Warnings, warnings...)
+	if _, err := vt.Target.Exec(result.Translated); err != nil {
+		result.Status, result.Error = ViewFailed, err.Error()
+	} else if vt.Fingerprints != nil {
+		vt.Fingerprints[result.Target] = fingerprint
+	}
+	return result
+}
+
+// report - record the result of the view and print it
+func (vt *ViewTask) report(result ViewResult) {
+	result.Database = vt.Database
+	vt.Results = append(vt.Results, result)
+	name := result.Name
+	if 0 < len(result.Target) && result.Target != result.Name {
+		name += " -> " + result.Target
+	}
+	if len(result.Error) <= 0 {
+		fmt.Printf("  VIEW %s %s\n", name, result.Status)
+	} else {
+		fmt.Printf("  VIEW %s %s: %s\n", name, result.Status, result.Error)
+	}
+	for _, w := range result.Warnings {
+		fmt.Printf("    WARN %s\n", w)
+	}
+}
+
+// removedViews - target views applied from source views that no longer exist or are not selected,
+// views(key) by the target names
+func removedViews(views map[string]string, newViews map[string]string, fingerprints map[string]string) []string {
+	removed := make([]string, 0)
+	for vname := range newViews {
+		_, applied := fingerprints[vname]
+		if _, exists := views[vname]; applied && !exists {
+			removed = append(removed, vname)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// dropRemovedViews - drop the target views removed from the source,
+// only those applied by the service are dropped
+func (vt *ViewTask) dropRemovedViews(views map[string]string, newViews map[string]string) {
+	if vt.Fingerprints == nil {
+		fmt.Println("drop_removed takes the fingerprints file to know the views applied")
+		return
+	}
+	for _, vname := range removedViews(views, newViews, vt.Fingerprints) {
+		query := fmt.Sprintf("DROP VIEW IF EXISTS %s", quoteMySQL(vname))
+		if _, err := vt.Target.Exec(query); err != nil {
+			vt.report(ViewResult{Name: vname, Status: ViewFailed, Translated: query, Error: err.Error()})
+			continue
+		}
+		vt.report(ViewResult{Name: vname, Status: ViewDropped})
+		delete(vt.Fingerprints, vname)
+	}
+}
+
+// ViewDependency - object a view selects from
+type ViewDependency struct {
+	Name     string
+	Owner    string // owner of the object, dbo when empty
+	Database string // database of an external object
+	Kind     string
+}
+
+// readMSSQLViewDependencies - objects referenced by the views(key) on the source
+func readMSSQLViewDependencies(source *sql.DB) (map[string][]ViewDependency, error) {
+	query := `SELECT v.name, d.referenced_entity_name, d.referenced_database_name, o.type, d.referenced_schema_name
+	FROM sys.views v
+	JOIN sys.sql_expression_dependencies d ON d.referencing_id=v.object_id
+	LEFT JOIN sys.objects o ON o.object_id=d.referenced_id
+	WHERE d.referenced_minor_id=0`
+	rows, err := queryFetchAll(source, query)
+	if err != nil {
+		return nil, err
+	}
+	deps := make(map[string][]ViewDependency)
+	for _, row := range rows {
+		dep := ViewDependency{Name: transformString(row[1]), Kind: DependencyMissing}
+		if row[4] != nil {
+			dep.Owner = transformString(row[4])
+		}
+		if row[2] != nil {
+			dep.Database, dep.Kind = transformString(row[2]), DependencyExternal
+		}
+		switch strings.TrimSpace(transformString(row[3])) {
+		case "V":
+			dep.Kind = DependencyView
+		case "U":
+			dep.Kind = DependencyTable
+		}
+		name := transformString(row[0])
+		deps[name] = append(deps[name], dep)
+	}
+	return deps, nil
+}
+
+// parseViewDependencies - objects following FROM and JOIN in the view definition,
+// views when they are among the views
+func parseViewDependencies(def string, views map[string]string) []ViewDependency {
+	tokens := make([]sqlToken, 0)
+	for _, t := range tokenizeSQL(def) {
+		if t.Kind != tokenSpace && t.Kind != tokenComment {
+			tokens = append(tokens, t)
+		}
+	}
+	keys := lowerKeys(views)
+	deps := make([]ViewDependency, 0)
+	for i := 0; i < len(tokens)-1; i++ {
+		if !tokens[i].is("FROM", "JOIN") || (tokens[i+1].Kind != tokenWord && tokens[i+1].Kind != tokenQuoted) {
+			continue
+		}
+		// the last part of a qualified name, database.owner.name
+		parts := []string{tokens[i+1].Text}
+		for j := i + 2; j+1 < len(tokens) && tokens[j].is(".") && (tokens[j+1].Kind == tokenWord || tokens[j+1].Kind == tokenQuoted); j += 2 {
+			parts = append(parts, tokens[j+1].Text)
+		}
+		dep := ViewDependency{Name: parts[len(parts)-1], Kind: DependencyTable}
+		if 2 <= len(parts) {
+			dep.Owner = parts[len(parts)-2]
+		}
+		if 3 <= len(parts) {
+			dep.Database, dep.Kind = parts[len(parts)-3], DependencyExternal
+		} else if _, exists := keys[strings.ToLower(dep.Name)]; exists {
+			dep.Kind = DependencyView
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+// sortViews - view names in dependency order, views selecting from others after them.
+// views in a cycle, or depending on one, are returned apart
+func sortViews(views map[string]string, deps map[string][]ViewDependency) ([]string, []string) {
+	names := make([]string, 0, len(views))
+	for name := range views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// pending views each view waits for, by the view names
+	keys := lowerKeys(views)
+	waits := make(map[string]map[string]bool, len(names))
+	for _, name := range names {
+		waits[name] = make(map[string]bool)
+		for _, dep := range deps[name] {
+			if key, exists := keys[strings.ToLower(dep.Name)]; exists && dep.Kind == DependencyView && key != name {
+				waits[name][key] = true
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for len(sorted) < len(names) {
+		ready := make([]string, 0)
+		for _, name := range names {
+			if w, pending := waits[name]; pending && len(w) <= 0 {
+				ready = append(ready, name)
+			}
+		}
+		if len(ready) <= 0 {
+			break
+		}
+		for _, name := range ready {
+			delete(waits, name)
+			for _, w := range waits {
+				delete(w, name)
+			}
+		}
+		sorted = append(sorted, ready...)
+	}
+
+	cyclic := make([]string, 0, len(waits))
+	for name := range waits {
+		cyclic = append(cyclic, name)
+	}
+	sort.Strings(cyclic)
+	return sorted, cyclic
+}
+
+// lowerKeys - view names by the names in lower case
+func lowerKeys(views map[string]string) map[string]string {
+	keys := make(map[string]string, len(views))
+	for name := range views {
+		keys[strings.ToLower(name)] = name
+	}
+	return keys
+}
+
+// viewDependencies - dependencies of the source views, parsed from the definitions
+// when the catalog is not readable
+func viewDependencies(source *sql.DB, views map[string]string) map[string][]ViewDependency {
+	deps, err := readMSSQLViewDependencies(source)
+	if err == nil {
+		// referenced views by the view names, the catalog keeps the case of the reference
+		keys := lowerKeys(views)
+		for _, list := range deps {
+			for i, dep := range list {
+				if key, exists := keys[strings.ToLower(dep.Name)]; exists && dep.Kind == DependencyView {
+					list[i].Name = key
+				}
+			}
+		}
+		return deps
+	}
+	fmt.Printf("view dependencies parsed from the definitions: %s\n", err.Error())
+	deps = make(map[string][]ViewDependency, len(views))
+	for name, def := range views {
+		deps[name] = parseViewDependencies(def, views)
+	}
+	return deps
+}
+
+// transformedTables - tables with transforms the view selects from, through the views it selects from
+func (vt ViewTask) transformedTables(name string, deps map[string][]ViewDependency, visited map[string]bool) []string {
+	visited[name] = true
+	tables := make([]string, 0)
+	for _, dep := range deps[name] {
+		switch {
+		case dep.Kind == DependencyView && !visited[dep.Name]:
+			tables = append(tables, vt.transformedTables(dep.Name, deps, visited)...)
+		case dep.Kind == DependencyTable && matchAny(vt.Transformed, dep.Name):
+			tables = append(tables, dep.Name)
+		}
+	}
+	return tables
+}
+
+// readMySQLTableNames - base tables of the target database
+func readMySQLTableNames(target *sql.DB) []string {
+	rows, err := queryFetchAll(target, "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA=DATABASE() AND TABLE_TYPE='BASE TABLE'")
+	if err != nil {
+		fmt.Println(err.Error())
+		return nil
+	}
+	names := make([]string, len(rows))
+	for i, row := range rows {
+		names[i] = transformString(row[0])
+	}
+	return names
+}
+
+// missingTables - tables the view selects from, not found on the source or on the target
+// by the name the translated view selects from
+func missingTables(deps []ViewDependency, tables []string, names ObjectNames) []string {
+	missing := make([]string, 0)
+	for _, dep := range deps {
+		if dep.Kind == DependencyMissing || (dep.Kind == DependencyTable && !containsName(tables, names.target(names.Schema, dep.Owner, dep.Name))) {
+			if !containsName(missing, dep.Name) {
+				missing = append(missing, dep.Name)
+			}
+		}
+	}
+	return missing
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortViews(t *testing.T) {
+	views := map[string]string{"a": "", "b": "", "c": "", "d": "", "x": "", "y": "", "z": ""}
+	deps := map[string][]ViewDependency{
+		"a": {{Name: "b", Kind: DependencyView}, {Name: "Cleansed_Dataset", Kind: DependencyTable}},
+		"b": {{Name: "c", Kind: DependencyView}},
+		"d": {{Name: "a", Kind: DependencyView}, {Name: "c", Kind: DependencyView}},
+		// x and y select from each other, z from x
+		"x": {{Name: "y", Kind: DependencyView}},
+		"y": {{Name: "x", Kind: DependencyView}},
+		"z": {{Name: "x", Kind: DependencyView}},
+	}
+	order, cyclic := sortViews(views, deps)
+	if strings.Join(order, ",") != "c,b,a,d" {
+		t.Errorf("expected c,b,a,d but %v", order)
+	}
+	if strings.Join(cyclic, ",") != "x,y,z" {
+		t.Errorf("expected x,y,z in cycles but %v", cyclic)
+	}
+
+	// references are not case sensitive
+	views = map[string]string{"A_Top": "", "v_base": ""}
+	deps = map[string][]ViewDependency{"A_Top": {{Name: "V_BASE", Kind: DependencyView}}}
+	if order, _ = sortViews(views, deps); strings.Join(order, ",") != "v_base,A_Top" {
+		t.Errorf("expected v_base,A_Top but %v", order)
+	}
+	if deps := parseViewDependencies("SELECT * FROM V_BASE", views); len(deps) != 1 || deps[0].Kind != DependencyView {
+		t.Errorf("expected view dependency but %v", deps)
+	}
+}
+
+func TestParseViewDependencies(t *testing.T) {
+	views := map[string]string{"v_base": ""}
+	def := `CREATE VIEW [dbo].[v] AS
+	SELECT a.x -- FROM commented
+	FROM [dbo].[v_base] a WITH (NOLOCK)
+	JOIN Orders o ON o.x = a.x
+	LEFT JOIN [CheilOptimizer_DM].dbo.Cleansed_Dataset c ON c.x = a.x
+	WHERE a.s <> 'FROM nowhere'`
+
+	deps := parseViewDependencies(def, views)
+	expects := []ViewDependency{
+		{Name: "v_base", Owner: "dbo", Kind: DependencyView},
+		{Name: "Orders", Kind: DependencyTable},
+		{Name: "Cleansed_Dataset", Owner: "dbo", Database: "CheilOptimizer_DM", Kind: DependencyExternal},
+	}
+	if len(deps) != len(expects) {
+		t.Fatalf("expected %v but %v", expects, deps)
+	}
+	for i, e := range expects {
+		if deps[i] != e {
+			t.Errorf("[%d] expected %v but %v", i, e, deps[i])
+		}
+	}
+
+	missing := missingTables(append(deps, ViewDependency{Name: "gone", Kind: DependencyMissing}), []string{"orders"}, ObjectNames{})
+	if strings.Join(missing, ",") != "gone" {
+		t.Errorf("expected gone missing but %v", missing)
+	}
+	// the target tables are found by the target names
+	names := ObjectNames{Schema: "Cheil", Tables: map[string]string{"cheil.dbo.orders": "legacy_orders"}}
+	if missing := missingTables(deps, []string{"legacy_orders"}, names); len(missing) != 0 {
+		t.Errorf("expected none missing but %v", missing)
+	}
+	if missing := missingTables(deps, []string{"orders"}, names); strings.Join(missing, ",") != "Orders" {
+		t.Errorf("expected Orders missing but %v", missing)
+	}
+}
+
+func TestFingerprintView(t *testing.T) {
+	query := "CREATE VIEW `v` AS SELECT `a`, 'x  y' FROM t"
+	same := "-- applied\ncreate view `v` as\n  select `a`,   'x  y'\n  from t"
+	if fingerprintView(query) != fingerprintView(same) {
+		t.Error("spacing, comments and keyword case expected to keep the fingerprint")
+	}
+	for _, changed := range []string{
+		"CREATE VIEW `v` AS SELECT `a`, 'x y' FROM t",
+		"CREATE VIEW `v` AS SELECT `b`, 'x  y' FROM t",
+	} {
+		if fingerprintView(query) == fingerprintView(changed) {
+			t.Errorf("%s expected to change the fingerprint", changed)
+		}
+	}
+
+	replaced := orReplaceView("/* v */\nCREATE  view `v` AS SELECT 'CREATE VIEW'")
+	if replaced != "/* v */\nCREATE OR REPLACE VIEW `v` AS SELECT 'CREATE VIEW'" {
+		t.Errorf("unexpected %s", replaced)
+	}
+}
+
+func TestRemovedViews(t *testing.T) {
+	oldViews := map[string]string{"kept": ""}
+	newViews := map[string]string{"kept": "", "removed": "", "manual": ""}
+	fingerprints := map[string]string{"kept": "1", "removed": "2"}
+	// views not applied by the service are not taken as removed
+	if removed := removedViews(oldViews, newViews, fingerprints); strings.Join(removed, ",") != "removed" {
+		t.Errorf("expected removed but %v", removed)
+	}
+	if fingerprints := (ViewFingerprints)(nil).Database("cheil"); fingerprints != nil {
+		t.Error("fingerprints expected to be nil when not recorded")
+	}
+}
+
+func TestExternalReferences(t *testing.T) {
+	vt := ViewTask{Schema: "Sales", Databases: map[string]string{"sales": "sales", "cheiloptimizer_dm": "dm"}}
+	deps := []ViewDependency{
+		{Name: "orders", Kind: DependencyTable},
+		{Name: "Cleansed_Dataset", Owner: "dbo", Database: "CheilOptimizer_DM", Kind: DependencyExternal},
+		{Name: "v_base", Database: "sales", Kind: DependencyExternal},
+		{Name: "Rates", Database: "Finance", Kind: DependencyExternal},
+	}
+	if refs := vt.externalReferences(deps); len(refs) != 1 || refs[0] != "Finance.Rates" {
+		t.Errorf("expected [Finance.Rates] but %v", refs)
+	}
+}
+
+func TestTransformedTables(t *testing.T) {
+	vt := ViewTask{Transformed: []string{"Members", "Contact_*"}}
+	deps := map[string][]ViewDependency{
+		"v_members": {{Name: "Members", Kind: DependencyTable}, {Name: "Orders", Kind: DependencyTable}},
+		"v_summary": {{Name: "v_members", Kind: DependencyView}, {Name: "contact_phone", Kind: DependencyTable}},
+		"v_orders":  {{Name: "Orders", Kind: DependencyTable}},
+	}
+	if tables := vt.transformedTables("v_summary", deps, map[string]bool{}); len(tables) != 2 {
+		t.Errorf("expected Members and contact_phone but %v", tables)
+	}
+	if tables := vt.transformedTables("v_orders", deps, map[string]bool{}); len(tables) != 0 {
+		t.Errorf("unexpected %v", tables)
+	}
+}